@@ -0,0 +1,10 @@
+BEGIN;
+
+CREATE TABLE materialize_snapshots (
+    name text PRIMARY KEY,
+    version integer NOT NULL,
+    data jsonb NOT NULL,
+    taken_at timestamptz NOT NULL
+);
+
+COMMIT;
@@ -0,0 +1,48 @@
+BEGIN;
+
+CREATE TABLE rollup_processed_events (
+    event_id bigint NOT NULL,
+    created_at timestamptz NOT NULL,
+    PRIMARY KEY (event_id, created_at)
+);
+
+CREATE TABLE player_rollups (
+    period text NOT NULL CHECK (period IN ('hour', 'day')),
+    bucket timestamptz NOT NULL,
+    player_id bigint NOT NULL,
+    events bigint NOT NULL DEFAULT 0,
+    bets bigint NOT NULL DEFAULT 0,
+    wagered_eur bigint NOT NULL DEFAULT 0,
+    deposits bigint NOT NULL DEFAULT 0,
+    deposits_eur bigint NOT NULL DEFAULT 0,
+    wins bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, bucket, player_id)
+);
+
+CREATE TABLE game_rollups (
+    period text NOT NULL CHECK (period IN ('hour', 'day')),
+    bucket timestamptz NOT NULL,
+    game_id bigint NOT NULL,
+    events bigint NOT NULL DEFAULT 0,
+    bets bigint NOT NULL DEFAULT 0,
+    wagered_eur bigint NOT NULL DEFAULT 0,
+    deposits bigint NOT NULL DEFAULT 0,
+    deposits_eur bigint NOT NULL DEFAULT 0,
+    wins bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, bucket, game_id)
+);
+
+CREATE TABLE currency_rollups (
+    period text NOT NULL CHECK (period IN ('hour', 'day')),
+    bucket timestamptz NOT NULL,
+    currency text NOT NULL,
+    events bigint NOT NULL DEFAULT 0,
+    bets bigint NOT NULL DEFAULT 0,
+    wagered_eur bigint NOT NULL DEFAULT 0,
+    deposits bigint NOT NULL DEFAULT 0,
+    deposits_eur bigint NOT NULL DEFAULT 0,
+    wins bigint NOT NULL DEFAULT 0,
+    PRIMARY KEY (period, bucket, currency)
+);
+
+COMMIT;
@@ -0,0 +1,19 @@
+BEGIN;
+
+CREATE TABLE events (
+    id bigint NOT NULL,
+    created_at timestamptz NOT NULL,
+    player_id bigint NOT NULL,
+    game_id bigint,
+    type text NOT NULL,
+    data jsonb NOT NULL,
+    stored_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (id, created_at)
+);
+
+CREATE INDEX events_player_id_idx ON events (player_id, created_at);
+CREATE INDEX events_game_id_idx ON events (game_id, created_at);
+CREATE INDEX events_type_idx ON events (type, created_at);
+CREATE INDEX events_created_at_idx ON events (created_at);
+
+COMMIT;
@@ -0,0 +1,34 @@
+BEGIN;
+
+CREATE TABLE ledger_transactions (
+    id bigserial PRIMARY KEY,
+    event_id bigint NOT NULL,
+    event_created_at timestamptz NOT NULL,
+    player_id bigint NOT NULL,
+    currency text NOT NULL,
+    kind text NOT NULL CHECK (kind IN ('deposit', 'bet', 'win')),
+    amount bigint NOT NULL,
+    balance_after bigint NOT NULL,
+    flagged boolean NOT NULL DEFAULT false,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (event_id, event_created_at, kind)
+);
+
+CREATE INDEX ledger_transactions_player_id_idx ON ledger_transactions (player_id, id);
+
+CREATE TABLE ledger_entries (
+    transaction_id bigint NOT NULL REFERENCES ledger_transactions (id),
+    account text NOT NULL,
+    amount bigint NOT NULL,
+    PRIMARY KEY (transaction_id, account)
+);
+
+CREATE TABLE wallet_balances (
+    player_id bigint NOT NULL,
+    currency text NOT NULL,
+    balance bigint NOT NULL DEFAULT 0,
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (player_id, currency)
+);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE player_rollups ADD COLUMN won_eur bigint NOT NULL DEFAULT 0;
+ALTER TABLE game_rollups ADD COLUMN won_eur bigint NOT NULL DEFAULT 0;
+ALTER TABLE currency_rollups ADD COLUMN won_eur bigint NOT NULL DEFAULT 0;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+ALTER TABLE players ADD COLUMN preferred_currency text;
+ALTER TABLE players ADD COLUMN segment text NOT NULL DEFAULT 'casual' CHECK (segment IN ('casual', 'whale'));
+
+UPDATE players SET preferred_currency = 'EUR' WHERE id = 10;
+UPDATE players SET preferred_currency = 'USD', segment = 'whale' WHERE id = 11;
+UPDATE players SET preferred_currency = 'GBP' WHERE id = 12;
+UPDATE players SET preferred_currency = 'BTC' WHERE id = 13;
+UPDATE players SET preferred_currency = 'NZD' WHERE id = 14;
+
+COMMIT;
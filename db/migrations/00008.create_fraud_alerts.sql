@@ -0,0 +1,18 @@
+BEGIN;
+
+CREATE TABLE fraud_alerts (
+    id bigserial PRIMARY KEY,
+    rule text NOT NULL,
+    player_id bigint NOT NULL,
+    event_id bigint NOT NULL,
+    event_created_at timestamptz NOT NULL,
+    value double precision NOT NULL,
+    threshold double precision NOT NULL,
+    message text NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT now(),
+    UNIQUE (rule, event_id, event_created_at)
+);
+
+CREATE INDEX fraud_alerts_player_id_idx ON fraud_alerts (player_id, id);
+
+COMMIT;
@@ -0,0 +1,44 @@
+BEGIN;
+
+ALTER TABLE players ADD COLUMN self_excluded boolean NOT NULL DEFAULT false;
+ALTER TABLE players ADD COLUMN self_exclusion_ends timestamptz;
+
+CREATE TABLE player_limits (
+    player_id bigint NOT NULL,
+    kind text NOT NULL CHECK (kind IN ('deposit', 'loss')),
+    period text NOT NULL CHECK (period IN ('daily', 'weekly', 'monthly')),
+    amount_eur bigint NOT NULL CHECK (amount_eur >= 0),
+    pending_amount_eur bigint CHECK (pending_amount_eur >= 0),
+    pending_from timestamptz,
+    updated_at timestamptz NOT NULL DEFAULT now(),
+    PRIMARY KEY (player_id, kind, period)
+);
+
+CREATE TABLE limit_usage (
+    player_id bigint NOT NULL,
+    kind text NOT NULL,
+    period text NOT NULL,
+    period_start timestamptz NOT NULL,
+    amount_eur bigint NOT NULL,
+    PRIMARY KEY (player_id, kind, period, period_start)
+);
+
+CREATE TABLE limit_events (
+    event_id bigint NOT NULL,
+    event_created_at timestamptz NOT NULL,
+    kind text NOT NULL,
+    PRIMARY KEY (event_id, event_created_at, kind)
+);
+
+CREATE TABLE limit_breaches (
+    player_id bigint NOT NULL,
+    type text NOT NULL,
+    kind text NOT NULL,
+    period text NOT NULL,
+    period_start timestamptz NOT NULL,
+    event_id bigint NOT NULL,
+    event_created_at timestamptz NOT NULL,
+    PRIMARY KEY (player_id, type, kind, period, period_start)
+);
+
+COMMIT;
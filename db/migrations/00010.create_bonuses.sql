@@ -0,0 +1,27 @@
+BEGIN;
+
+CREATE TABLE bonuses (
+    id bigserial PRIMARY KEY,
+    player_id bigint NOT NULL,
+    campaign_id text NOT NULL,
+    deposit_event_id bigint NOT NULL,
+    amount_eur bigint NOT NULL,
+    required_eur bigint NOT NULL,
+    wagered_eur bigint NOT NULL DEFAULT 0,
+    status text NOT NULL CHECK (status IN ('active', 'completed', 'expired')),
+    awarded_at timestamptz NOT NULL,
+    expires_at timestamptz,
+    ended_at timestamptz,
+    UNIQUE (player_id, campaign_id)
+);
+
+CREATE INDEX bonuses_active_idx ON bonuses (player_id, awarded_at) WHERE status = 'active';
+CREATE INDEX bonuses_expiry_idx ON bonuses (expires_at) WHERE status = 'active';
+
+CREATE TABLE bonus_events (
+    event_id bigint NOT NULL,
+    event_created_at timestamptz NOT NULL,
+    PRIMARY KEY (event_id, event_created_at)
+);
+
+COMMIT;
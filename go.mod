@@ -3,9 +3,11 @@ module github.com/Bitstarz-eng/event-processing-challenge
 go 1.18
 
 require (
+	github.com/gorilla/websocket v1.5.0
 	github.com/lib/pq v1.10.9
 	github.com/rs/zerolog v1.33.0
 	github.com/streadway/amqp v1.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
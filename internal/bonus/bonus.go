@@ -0,0 +1,243 @@
+// Package bonus This awards deposit bonuses per campaign and tracks their wagering requirements.
+package bonus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// Queue is the RabbitMQ queue bonus events are published to.
+const Queue = "casino_bonuses"
+
+// Statuses of bonuses.
+const (
+	StatusActive    = "active"
+	StatusCompleted = "completed"
+	StatusExpired   = "expired"
+)
+
+// Types of bonus events.
+const (
+	EventAwarded   = "bonus_awarded"
+	EventCompleted = "bonus_completed"
+	EventExpired   = "bonus_expired"
+)
+
+// ErrDuplicate is returned when the bet was already counted, or the player
+// was already awarded a bonus of the campaign.
+var ErrDuplicate = errors.New("already counted")
+
+// Bonus is a bonus awarded to a player on a deposit. It completes once the
+// player wagered RequiredEUR, or expires at ExpiresAt if set. Amounts are in
+// euro cents, like the amount_eur of events.
+type Bonus struct {
+	ID             int64      `json:"id"`
+	PlayerID       int        `json:"player_id"`
+	CampaignID     string     `json:"campaign_id"`
+	DepositEventID int        `json:"deposit_event_id"`
+	AmountEUR      int        `json:"amount_eur"`
+	RequiredEUR    int        `json:"required_eur"`
+	WageredEUR     int        `json:"wagered_eur"`
+	Status         string     `json:"status"`
+	AwardedAt      time.Time  `json:"awarded_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	EndedAt        *time.Time `json:"ended_at,omitempty"`
+}
+
+// Event is published when a bonus is awarded, completed or expired. The
+// event fields are those of the deposit or bet that caused it, or for
+// bonuses expired by Expire, no ID and the expiry time.
+type Event struct {
+	Type           string    `json:"type"`
+	EventID        int       `json:"event_id,omitempty"`
+	EventCreatedAt time.Time `json:"event_created_at"`
+	Bonus          Bonus     `json:"bonus"`
+}
+
+// Wager is the weighted amount of a bet counted towards the active bonuses
+// of its player.
+type Wager struct {
+	EventID        int
+	EventCreatedAt time.Time
+	PlayerID       int
+	AmountEUR      int
+}
+
+// Store keeps the bonuses of every player.
+type Store interface {
+	// Award stores a new bonus and returns it with its ID, or ErrDuplicate if
+	// the player was already awarded a bonus of the campaign.
+	Award(ctx context.Context, bonus Bonus) (Bonus, error)
+	// Wager applies the wager to the active bonuses of the player with
+	// applyWager and returns the bonuses it changed, or ErrDuplicate if the
+	// bet was already counted.
+	Wager(ctx context.Context, wager Wager) ([]Bonus, error)
+	// Bonuses returns the bonuses of the player, most recent first, as stored.
+	Bonuses(ctx context.Context, playerID int) ([]Bonus, error)
+	// Expire marks the active bonuses that expired before now as expired and
+	// returns them.
+	Expire(ctx context.Context, now time.Time) ([]Bonus, error)
+}
+
+// Tracker awards bonuses on deposits and counts bets towards wagering.
+type Tracker struct {
+	store     Store
+	campaigns Campaigns
+}
+
+// NewTracker creates a new Tracker.
+func NewTracker(store Store, campaigns Campaigns) *Tracker {
+	return &Tracker{store: store, campaigns: campaigns}
+}
+
+// Track applies the event to the bonuses of its player and returns the
+// resulting bonus events. Only deposits and bets are tracked.
+func (t *Tracker) Track(ctx context.Context, event casino.Event) ([]Event, error) {
+	switch event.Type {
+	case "deposit":
+		return t.award(ctx, event)
+	case "bet":
+		return t.wager(ctx, event)
+	default:
+		return nil, nil
+	}
+}
+
+// award awards the bonuses of the campaigns the deposit qualifies for.
+func (t *Tracker) award(ctx context.Context, event casino.Event) ([]Event, error) {
+	var events []Event
+	for _, campaign := range t.campaigns.Campaigns {
+		if !campaign.Running(event.CreatedAt) {
+			continue
+		}
+		amount := campaign.BonusEUR(event.AmountEUR)
+		if amount == 0 {
+			continue
+		}
+
+		bonus := Bonus{
+			PlayerID:       event.PlayerID,
+			CampaignID:     campaign.ID,
+			DepositEventID: event.ID,
+			AmountEUR:      amount,
+			RequiredEUR:    amount * campaign.WageringMultiplier,
+			Status:         StatusActive,
+			AwardedAt:      event.CreatedAt,
+		}
+		if campaign.ValidFor > 0 {
+			expires := event.CreatedAt.Add(campaign.ValidFor)
+			bonus.ExpiresAt = &expires
+		}
+
+		bonus, err := t.store.Award(ctx, bonus)
+		if errors.Is(err, ErrDuplicate) {
+			continue
+		} else if err != nil {
+			return events, fmt.Errorf("error awarding bonus: %w", err)
+		}
+		events = append(events, Event{Type: EventAwarded, EventID: event.ID, EventCreatedAt: event.CreatedAt, Bonus: bonus})
+	}
+	return events, nil
+}
+
+// wager counts the bet, weighted by the category of its game, towards the
+// active bonuses of the player.
+func (t *Tracker) wager(ctx context.Context, event casino.Event) ([]Event, error) {
+	weight := t.campaigns.Weights[casino.Games[event.GameID].Category]
+	changed, err := t.store.Wager(ctx, Wager{
+		EventID:        event.ID,
+		EventCreatedAt: event.CreatedAt,
+		PlayerID:       event.PlayerID,
+		AmountEUR:      int(float64(event.AmountEUR) * weight),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var events []Event
+	for _, bonus := range changed {
+		e := Event{EventID: event.ID, EventCreatedAt: event.CreatedAt, Bonus: bonus}
+		switch bonus.Status {
+		case StatusCompleted:
+			e.Type = EventCompleted
+		case StatusExpired:
+			e.Type = EventExpired
+		default:
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// Bonuses returns the bonuses of the player at now, most recent first.
+// Active bonuses past their expiry are returned as expired, even if Expire
+// didn't mark them yet.
+func (t *Tracker) Bonuses(ctx context.Context, playerID int, now time.Time) ([]Bonus, error) {
+	bonuses, err := t.store.Bonuses(ctx, playerID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bonuses {
+		bonuses[i] = bonuses[i].At(now)
+	}
+	return bonuses, nil
+}
+
+// Expire expires the active bonuses that expired before now, e.g. of players
+// who stopped betting, and returns the resulting bonus events.
+func (t *Tracker) Expire(ctx context.Context, now time.Time) ([]Event, error) {
+	expired, err := t.store.Expire(ctx, now)
+	if err != nil {
+		return nil, fmt.Errorf("error expiring bonuses: %w", err)
+	}
+
+	events := make([]Event, 0, len(expired))
+	for _, bonus := range expired {
+		events = append(events, Event{Type: EventExpired, EventCreatedAt: *bonus.ExpiresAt, Bonus: bonus})
+	}
+	return events, nil
+}
+
+// At returns the bonus at t, expired if it was active and expired before t.
+func (b Bonus) At(t time.Time) Bonus {
+	if b.Status == StatusActive && b.ExpiresAt != nil && t.After(*b.ExpiresAt) {
+		b.Status = StatusExpired
+		b.EndedAt = b.ExpiresAt
+	}
+	return b
+}
+
+// applyWager expires the active bonuses that expired before at and counts
+// the amount towards the others, oldest first, carrying what exceeds the
+// requirement of a bonus over to the next. It returns the changed bonuses.
+func applyWager(active []Bonus, amountEUR int, at time.Time) []Bonus {
+	var changed []Bonus
+	for _, bonus := range active {
+		if bonus = bonus.At(at); bonus.Status == StatusExpired {
+			changed = append(changed, bonus)
+			continue
+		}
+		if amountEUR <= 0 {
+			continue
+		}
+
+		counted := bonus.RequiredEUR - bonus.WageredEUR
+		if amountEUR < counted {
+			counted = amountEUR
+		}
+		bonus.WageredEUR += counted
+		amountEUR -= counted
+		if bonus.WageredEUR >= bonus.RequiredEUR {
+			bonus.Status = StatusCompleted
+			ended := at
+			bonus.EndedAt = &ended
+		}
+		changed = append(changed, bonus)
+	}
+	return changed
+}
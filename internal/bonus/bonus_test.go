@@ -0,0 +1,248 @@
+package bonus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/enrichment"
+)
+
+func testCampaigns() Campaigns {
+	return Campaigns{
+		Weights: map[string]float64{casino.CategorySlots: 1, casino.CategoryDice: 0.1},
+		Campaigns: []Campaign{
+			{ID: "welcome", MinDepositEUR: 1000, MatchPercent: 100, MaxBonusEUR: 5000, WageringMultiplier: 2, ValidFor: 24 * time.Hour},
+		},
+	}
+}
+
+func TestLoadCampaigns(t *testing.T) {
+	campaigns, err := LoadCampaigns("../../campaigns/default.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(campaigns.Campaigns) == 0 || campaigns.Campaigns[0].ValidFor != 720*time.Hour {
+		t.Errorf("Expected the welcome campaign valid for 720h, got %+v", campaigns.Campaigns)
+	}
+	if campaigns.Weights[casino.CategorySlots] != 1 {
+		t.Errorf("Expected slots to count fully, got %v", campaigns.Weights)
+	}
+
+	invalid := map[string]string{
+		"unknown key":    "campaigns:\n  - {id: a, match_percent: 100, wagering_multiplier: 30, bonus: 1}\n",
+		"missing id":     "campaigns:\n  - {match_percent: 100, wagering_multiplier: 30}\n",
+		"duplicate id":   "campaigns:\n  - {id: a, match_percent: 100, wagering_multiplier: 30}\n  - {id: a, match_percent: 50, wagering_multiplier: 30}\n",
+		"no wagering":    "campaigns:\n  - {id: a, match_percent: 100}\n",
+		"negative":       "weights: {slots: -1}\n",
+		"ends too early": "campaigns:\n  - {id: a, match_percent: 100, wagering_multiplier: 30, starts_at: 2025-02-01T00:00:00Z, ends_at: 2025-01-01T00:00:00Z}\n",
+	}
+	dir := t.TempDir()
+	for name, content := range invalid {
+		path := filepath.Join(dir, "campaigns.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCampaigns(path); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
+
+func TestBonusEUR(t *testing.T) {
+	campaign := testCampaigns().Campaigns[0]
+	tests := map[int]int{500: 0, 1000: 1000, 3000: 3000, 8000: 5000}
+	for deposit, want := range tests {
+		if got := campaign.BonusEUR(deposit); got != want {
+			t.Errorf("Expected a bonus of %d for a deposit of %d, got %d", want, deposit, got)
+		}
+	}
+
+	campaign.StartsAt = time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	campaign.EndsAt = time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	if campaign.Running(time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)) || !campaign.Running(campaign.StartsAt) || campaign.Running(campaign.EndsAt) {
+		t.Errorf("Expected the campaign to run from starts_at until ends_at")
+	}
+}
+
+func TestTrack(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(NewMemoryStore(), testCampaigns())
+	at := time.Date(2025, 2, 19, 10, 0, 0, 0, time.UTC)
+
+	events, err := tracker.Track(ctx, casino.Event{ID: 1, PlayerID: 10, Type: "deposit", AmountEUR: 3000, CreatedAt: at})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventAwarded || events[0].Bonus.RequiredEUR != 6000 {
+		t.Fatalf("Expected a bonus requiring 6000 to be awarded, got %+v", events)
+	}
+
+	// Only one bonus per campaign.
+	events, _ = tracker.Track(ctx, casino.Event{ID: 2, PlayerID: 10, Type: "deposit", AmountEUR: 3000, CreatedAt: at})
+	if len(events) != 0 {
+		t.Errorf("Expected no second bonus, got %+v", events)
+	}
+
+	bets := []struct {
+		event   casino.Event
+		wagered int
+		typ     string
+	}{
+		{casino.Event{ID: 3, GameID: 103, AmountEUR: 4000}, 4000, ""},
+		// Dice count for 10%.
+		{casino.Event{ID: 4, GameID: 100, AmountEUR: 5000}, 4500, ""},
+		{casino.Event{ID: 5, GameID: 103, AmountEUR: 2000}, 6000, EventCompleted},
+	}
+	for _, bet := range bets {
+		bet.event.PlayerID = 10
+		bet.event.Type = "bet"
+		bet.event.CreatedAt = at.Add(time.Hour)
+		events, err := tracker.Track(ctx, bet.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		bonuses, _ := tracker.Bonuses(ctx, 10, bet.event.CreatedAt)
+		if bonuses[0].WageredEUR != bet.wagered {
+			t.Errorf("Bet %d: expected %d wagered, got %d", bet.event.ID, bet.wagered, bonuses[0].WageredEUR)
+		}
+		if bet.typ == "" && len(events) != 0 || bet.typ != "" && (len(events) != 1 || events[0].Type != bet.typ) {
+			t.Errorf("Bet %d: expected event %q, got %+v", bet.event.ID, bet.typ, events)
+		}
+	}
+
+	// Redelivered bets are counted once.
+	_, err = tracker.Track(ctx, casino.Event{ID: 5, PlayerID: 10, Type: "bet", GameID: 103, AmountEUR: 2000, CreatedAt: at.Add(time.Hour)})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestTrackExpired(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(NewMemoryStore(), testCampaigns())
+	at := time.Date(2025, 2, 19, 10, 0, 0, 0, time.UTC)
+
+	tracker.Track(ctx, casino.Event{ID: 1, PlayerID: 10, Type: "deposit", AmountEUR: 3000, CreatedAt: at})
+	events, err := tracker.Track(ctx, casino.Event{ID: 2, PlayerID: 10, Type: "bet", GameID: 103, AmountEUR: 6000, CreatedAt: at.Add(25 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventExpired || events[0].Bonus.WageredEUR != 0 {
+		t.Errorf("Expected the bonus to expire without counting the bet, got %+v", events)
+	}
+}
+
+func TestExpire(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(NewMemoryStore(), testCampaigns())
+	at := time.Date(2025, 2, 19, 10, 0, 0, 0, time.UTC)
+	tracker.Track(ctx, casino.Event{ID: 1, PlayerID: 10, Type: "deposit", AmountEUR: 3000, CreatedAt: at})
+
+	// Reads report the expiry before the sweep marks it.
+	bonuses, err := tracker.Bonuses(ctx, 10, at.Add(25*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bonuses[0].Status != StatusExpired || !bonuses[0].EndedAt.Equal(at.Add(24*time.Hour)) {
+		t.Errorf("Expected the bonus to read as expired, got %+v", bonuses[0])
+	}
+
+	if events, _ := tracker.Expire(ctx, at.Add(time.Hour)); len(events) != 0 {
+		t.Errorf("Expected nothing to expire within the validity, got %+v", events)
+	}
+	events, err := tracker.Expire(ctx, at.Add(25*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Type != EventExpired || events[0].Bonus.Status != StatusExpired {
+		t.Errorf("Expected a bonus expired event, got %+v", events)
+	}
+	if events, _ := tracker.Expire(ctx, at.Add(26*time.Hour)); len(events) != 0 {
+		t.Errorf("Expected the bonus to expire once, got %+v", events)
+	}
+}
+
+func TestMemoryStoreForgetsOldBets(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	at := time.Date(2025, 2, 19, 10, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 100; i++ {
+		store.Wager(ctx, Wager{EventID: i, EventCreatedAt: at.Add(time.Duration(i) * time.Hour), PlayerID: 10})
+	}
+	if len(store.counted) > 2*25+16 {
+		t.Errorf("Expected old bets to be forgotten, %d remembered", len(store.counted))
+	}
+
+	// Bets older than the window can't be told apart from redeliveries.
+	if _, err := store.Wager(ctx, Wager{EventID: 1000, EventCreatedAt: at, PlayerID: 10}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate for a bet older than the window, got %v", err)
+	}
+}
+
+// The producer converts deposits from the smallest unit of their currency
+// to euro cents, which campaign amounts are in.
+func TestAwardConvertedDeposit(t *testing.T) {
+	amountEUR := enrichment.ConvertToEURWithRates(100000, "BTC", map[string]float64{"BTC": 1 / 30000.0})
+	if amountEUR != 3000 {
+		t.Fatalf("Expected 0.001 BTC to be 3000 euro cents, got %d", amountEUR)
+	}
+
+	events, err := NewTracker(NewMemoryStore(), testCampaigns()).Track(context.Background(),
+		casino.Event{ID: 1, PlayerID: 10, Type: "deposit", Amount: 100000, Currency: "BTC", AmountEUR: amountEUR, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].Bonus.AmountEUR != 3000 {
+		t.Errorf("Expected a bonus of 3000 euro cents, got %+v", events)
+	}
+}
+
+func TestApplyWagerCarriesOver(t *testing.T) {
+	at := time.Date(2025, 2, 19, 10, 0, 0, 0, time.UTC)
+	active := []Bonus{
+		{ID: 1, RequiredEUR: 1000, WageredEUR: 800, Status: StatusActive},
+		{ID: 2, RequiredEUR: 1000, Status: StatusActive},
+	}
+	changed := applyWager(active, 500, at)
+	if len(changed) != 2 || changed[0].Status != StatusCompleted || changed[1].WageredEUR != 300 {
+		t.Errorf("Expected the first bonus to complete and 300 to carry over, got %+v", changed)
+	}
+}
+
+func TestServeBonuses(t *testing.T) {
+	ctx := context.Background()
+	tracker := NewTracker(NewMemoryStore(), testCampaigns())
+	tracker.Track(ctx, casino.Event{ID: 1, PlayerID: 10, Type: "deposit", AmountEUR: 3000, CreatedAt: time.Now()})
+
+	rec := httptest.NewRecorder()
+	tracker.ServeBonuses(rec, httptest.NewRequest(http.MethodGet, "/players/10/bonuses?status=active", nil), 10)
+	var bonuses []Bonus
+	if err := json.NewDecoder(rec.Body).Decode(&bonuses); err != nil {
+		t.Fatal(err)
+	}
+	if len(bonuses) != 1 || bonuses[0].CampaignID != "welcome" || bonuses[0].AmountEUR != 3000 {
+		t.Errorf("Unexpected bonuses: %+v", bonuses)
+	}
+
+	rec = httptest.NewRecorder()
+	tracker.ServeBonuses(rec, httptest.NewRequest(http.MethodGet, "/players/10/bonuses?status=completed", nil), 10)
+	if body := rec.Body.String(); body != "[]\n" {
+		t.Errorf("Expected no completed bonuses, got %s", body)
+	}
+
+	rec = httptest.NewRecorder()
+	tracker.ServeBonuses(rec, httptest.NewRequest(http.MethodGet, "/players/10/bonuses?status=pending", nil), 10)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown status, got %d", rec.Code)
+	}
+}
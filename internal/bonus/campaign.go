@@ -0,0 +1,108 @@
+package bonus
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Campaigns configures the bonus campaigns and how bets count towards their
+// wagering requirements.
+type Campaigns struct {
+	// Weights is the share of the AmountEUR of a bet counting towards
+	// wagering, per game category. Bets on other categories don't count.
+	Weights   map[string]float64 `yaml:"weights"`
+	Campaigns []Campaign         `yaml:"campaigns"`
+}
+
+// Campaign awards a bonus matching a share of qualifying deposits. Every
+// player is awarded at most one bonus per campaign.
+type Campaign struct {
+	ID string `yaml:"id"`
+	// MinDepositEUR is the smallest deposit that qualifies.
+	MinDepositEUR int `yaml:"min_deposit_eur"`
+	// MatchPercent is the bonus as a percentage of the deposit, capped at
+	// MaxBonusEUR unless it is zero.
+	MatchPercent int `yaml:"match_percent"`
+	MaxBonusEUR  int `yaml:"max_bonus_eur"`
+	// WageringMultiplier is how many times the bonus must be wagered.
+	WageringMultiplier int `yaml:"wagering_multiplier"`
+	// ValidFor is how long the player has to complete the wagering, forever if zero.
+	ValidFor time.Duration `yaml:"valid_for"`
+	// StartsAt and EndsAt limit the deposits that qualify, if set.
+	StartsAt time.Time `yaml:"starts_at"`
+	EndsAt   time.Time `yaml:"ends_at"`
+}
+
+// LoadCampaigns reads the campaigns from a YAML or JSON file.
+func LoadCampaigns(path string) (Campaigns, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Campaigns{}, fmt.Errorf("error reading campaigns: %w", err)
+	}
+
+	var campaigns Campaigns
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&campaigns); err != nil {
+		return Campaigns{}, fmt.Errorf("error decoding campaigns %s: %w", path, err)
+	}
+
+	if err := campaigns.Validate(); err != nil {
+		return Campaigns{}, fmt.Errorf("invalid campaigns %s: %w", path, err)
+	}
+	return campaigns, nil
+}
+
+// Validate checks that the campaigns have unique IDs and award bonuses with
+// a wagering requirement.
+func (c Campaigns) Validate() error {
+	for category, weight := range c.Weights {
+		if weight < 0 {
+			return fmt.Errorf("weight of %s must not be negative", category)
+		}
+	}
+
+	ids := make(map[string]bool)
+	for i, campaign := range c.Campaigns {
+		if campaign.ID == "" {
+			return fmt.Errorf("campaign #%d: id is required", i+1)
+		}
+		if ids[campaign.ID] {
+			return fmt.Errorf("campaign %s: duplicate id", campaign.ID)
+		}
+		ids[campaign.ID] = true
+
+		if campaign.MatchPercent <= 0 || campaign.WageringMultiplier <= 0 {
+			return fmt.Errorf("campaign %s: match_percent and wagering_multiplier must be positive", campaign.ID)
+		}
+		if campaign.MinDepositEUR < 0 || campaign.MaxBonusEUR < 0 || campaign.ValidFor < 0 {
+			return fmt.Errorf("campaign %s: min_deposit_eur, max_bonus_eur and valid_for must not be negative", campaign.ID)
+		}
+		if !campaign.StartsAt.IsZero() && !campaign.EndsAt.IsZero() && !campaign.EndsAt.After(campaign.StartsAt) {
+			return fmt.Errorf("campaign %s: ends_at must be after starts_at", campaign.ID)
+		}
+	}
+	return nil
+}
+
+// Running reports whether deposits made at t qualify for the campaign.
+func (c Campaign) Running(t time.Time) bool {
+	return (c.StartsAt.IsZero() || !t.Before(c.StartsAt)) && (c.EndsAt.IsZero() || t.Before(c.EndsAt))
+}
+
+// BonusEUR returns the bonus awarded for a deposit, or zero if the deposit
+// doesn't qualify.
+func (c Campaign) BonusEUR(depositEUR int) int {
+	if depositEUR <= 0 || depositEUR < c.MinDepositEUR {
+		return 0
+	}
+	bonus := depositEUR * c.MatchPercent / 100
+	if c.MaxBonusEUR > 0 && bonus > c.MaxBonusEUR {
+		bonus = c.MaxBonusEUR
+	}
+	return bonus
+}
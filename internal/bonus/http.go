@@ -0,0 +1,42 @@
+package bonus
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// ServeBonuses handles HTTP requests to the bonuses of a player, most recent
+// first, with their status at the time of the request. The status query
+// parameter filters by status, e.g. ?status=active.
+func (t *Tracker) ServeBonuses(w http.ResponseWriter, r *http.Request, playerID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status != "" && status != StatusActive && status != StatusCompleted && status != StatusExpired {
+		http.Error(w, "status must be active, completed or expired", http.StatusBadRequest)
+		return
+	}
+
+	bonuses, err := t.Bonuses(r.Context(), playerID, time.Now())
+	if err != nil {
+		http.Error(w, "failed to fetch bonuses", http.StatusInternalServerError)
+		return
+	}
+
+	result := []Bonus{}
+	for _, b := range bonuses {
+		if status == "" || b.Status == status {
+			result = append(result, b)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(result)
+	if err != nil {
+		return
+	}
+}
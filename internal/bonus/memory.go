@@ -0,0 +1,129 @@
+package bonus
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// countedWindow is how long before the newest counted bet the MemoryStore
+// remembers bets, to tell redeliveries apart. Older bets count as redelivered.
+const countedWindow = 24 * time.Hour
+
+// MemoryStore keeps bonuses in memory.
+type MemoryStore struct {
+	mu          sync.Mutex
+	nextID      int64
+	bonuses     map[int][]Bonus
+	counted     map[eventKey]bool
+	newest      time.Time
+	pruneCounts int
+}
+
+type eventKey struct {
+	eventID   int
+	createdAt time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		bonuses: make(map[int][]Bonus),
+		counted: make(map[eventKey]bool),
+	}
+}
+
+// Award stores a new bonus, unless the player has one of the campaign.
+func (s *MemoryStore) Award(ctx context.Context, bonus Bonus) (Bonus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, b := range s.bonuses[bonus.PlayerID] {
+		if b.CampaignID == bonus.CampaignID {
+			return Bonus{}, ErrDuplicate
+		}
+	}
+	s.nextID++
+	bonus.ID = s.nextID
+	s.bonuses[bonus.PlayerID] = append(s.bonuses[bonus.PlayerID], bonus)
+	return bonus, nil
+}
+
+// Wager applies the wager to the active bonuses of the player.
+func (s *MemoryStore) Wager(ctx context.Context, wager Wager) ([]Bonus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := eventKey{wager.EventID, wager.EventCreatedAt.UTC()}
+	if s.counted[key] || s.newest.Sub(wager.EventCreatedAt) > countedWindow {
+		return nil, ErrDuplicate
+	}
+	s.counted[key] = true
+	s.prune(wager.EventCreatedAt)
+
+	bonuses := s.bonuses[wager.PlayerID]
+	var active []Bonus
+	index := make(map[int64]int)
+	for i, b := range bonuses {
+		if b.Status == StatusActive {
+			active = append(active, b)
+			index[b.ID] = i
+		}
+	}
+
+	changed := applyWager(active, wager.AmountEUR, wager.EventCreatedAt)
+	for _, b := range changed {
+		bonuses[index[b.ID]] = b
+	}
+	return changed, nil
+}
+
+// prune forgets the bets older than countedWindow before the newest one
+// whenever the counted set has doubled. Callers hold mu.
+func (s *MemoryStore) prune(at time.Time) {
+	if at.After(s.newest) {
+		s.newest = at
+	}
+	if len(s.counted) <= s.pruneCounts {
+		return
+	}
+	for key := range s.counted {
+		if s.newest.Sub(key.createdAt) > countedWindow {
+			delete(s.counted, key)
+		}
+	}
+	s.pruneCounts = 2*len(s.counted) + 16
+}
+
+// Expire marks the active bonuses that expired before now as expired.
+func (s *MemoryStore) Expire(ctx context.Context, now time.Time) ([]Bonus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []Bonus
+	for _, bonuses := range s.bonuses {
+		for i, b := range bonuses {
+			if b.Status != StatusActive {
+				continue
+			}
+			if b = b.At(now); b.Status == StatusExpired {
+				bonuses[i] = b
+				expired = append(expired, b)
+			}
+		}
+	}
+	return expired, nil
+}
+
+// Bonuses returns the bonuses of the player, most recent first.
+func (s *MemoryStore) Bonuses(ctx context.Context, playerID int) ([]Bonus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bonuses := s.bonuses[playerID]
+	result := make([]Bonus, 0, len(bonuses))
+	for i := len(bonuses) - 1; i >= 0; i-- {
+		result = append(result, bonuses[i])
+	}
+	return result, nil
+}
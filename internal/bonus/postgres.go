@@ -0,0 +1,136 @@
+package bonus
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// PostgresStore keeps bonuses in Postgres.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore.
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Close closes the database connection.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+const bonusColumns = `id, player_id, campaign_id, deposit_event_id, amount_eur, required_eur, wagered_eur, status, awarded_at, expires_at, ended_at`
+
+// Award stores a new bonus, unless the player has one of the campaign.
+func (s *PostgresStore) Award(ctx context.Context, bonus Bonus) (Bonus, error) {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO bonuses (player_id, campaign_id, deposit_event_id, amount_eur, required_eur, wagered_eur, status, awarded_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (player_id, campaign_id) DO NOTHING
+		RETURNING id`,
+		bonus.PlayerID, bonus.CampaignID, bonus.DepositEventID, bonus.AmountEUR, bonus.RequiredEUR, bonus.WageredEUR,
+		bonus.Status, bonus.AwardedAt, bonus.ExpiresAt).Scan(&bonus.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Bonus{}, ErrDuplicate
+	} else if err != nil {
+		return Bonus{}, fmt.Errorf("error inserting bonus: %w", err)
+	}
+	return bonus, nil
+}
+
+// Wager applies the wager to the active bonuses of the player, locking them
+// and marking the bet as counted in the same transaction.
+func (s *PostgresStore) Wager(ctx context.Context, wager Wager) ([]Bonus, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO bonus_events (event_id, event_created_at) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		wager.EventID, wager.EventCreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("error marking event: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrDuplicate
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT `+bonusColumns+` FROM bonuses
+		WHERE player_id = $1 AND status = $2 ORDER BY awarded_at, id FOR UPDATE`, wager.PlayerID, StatusActive)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching active bonuses: %w", err)
+	}
+	active, err := scanBonuses(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := applyWager(active, wager.AmountEUR, wager.EventCreatedAt)
+	for _, b := range changed {
+		_, err := tx.ExecContext(ctx, `UPDATE bonuses SET wagered_eur = $2, status = $3, ended_at = $4 WHERE id = $1`,
+			b.ID, b.WageredEUR, b.Status, b.EndedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error updating bonus: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing wager: %w", err)
+	}
+	return changed, nil
+}
+
+// Bonuses returns the bonuses of the player, most recent first.
+func (s *PostgresStore) Bonuses(ctx context.Context, playerID int) ([]Bonus, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+bonusColumns+` FROM bonuses
+		WHERE player_id = $1 ORDER BY awarded_at DESC, id DESC`, playerID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching bonuses: %w", err)
+	}
+	return scanBonuses(rows)
+}
+
+// Expire marks the active bonuses that expired before now as expired. Bets
+// expiring a bonus concurrently lock its row, so it is returned once.
+func (s *PostgresStore) Expire(ctx context.Context, now time.Time) ([]Bonus, error) {
+	rows, err := s.db.QueryContext(ctx, `UPDATE bonuses SET status = $1, ended_at = expires_at
+		WHERE status = $2 AND expires_at < $3
+		RETURNING `+bonusColumns, StatusExpired, StatusActive, now)
+	if err != nil {
+		return nil, fmt.Errorf("error expiring bonuses: %w", err)
+	}
+	return scanBonuses(rows)
+}
+
+// scanBonuses reads and closes the rows of a query selecting bonusColumns.
+func scanBonuses(rows *sql.Rows) ([]Bonus, error) {
+	defer rows.Close()
+
+	var bonuses []Bonus
+	for rows.Next() {
+		var b Bonus
+		err := rows.Scan(&b.ID, &b.PlayerID, &b.CampaignID, &b.DepositEventID, &b.AmountEUR, &b.RequiredEUR,
+			&b.WageredEUR, &b.Status, &b.AwardedAt, &b.ExpiresAt, &b.EndedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning bonus: %w", err)
+		}
+		bonuses = append(bonuses, b)
+	}
+	return bonuses, rows.Err()
+}
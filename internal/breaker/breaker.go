@@ -0,0 +1,142 @@
+// Package breaker This stops calling a failing dependency for a while so callers fail fast.
+package breaker
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+)
+
+// ErrOpen is returned instead of calling the dependency while the breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a Breaker.
+type State int
+
+const (
+	// Closed lets every call through.
+	Closed State = iota
+	// HalfOpen lets a single trial call through to probe the dependency.
+	HalfOpen
+	// Open rejects every call.
+	Open
+)
+
+// String returns the name of the state.
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half_open"
+	default:
+		return "open"
+	}
+}
+
+// Breaker opens after Threshold consecutive failures and rejects calls for
+// OpenTimeout. Then a trial call decides whether it closes or opens again.
+// Its state and calls are exported as metrics labelled with its name.
+type Breaker struct {
+	name        string
+	threshold   int
+	openTimeout time.Duration
+	now         func() time.Time
+
+	mu       sync.Mutex
+	state    State
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// New creates a new closed Breaker.
+func New(name string, threshold int, openTimeout time.Duration) *Breaker {
+	b := &Breaker{name: name, threshold: threshold, openTimeout: openTimeout, now: time.Now}
+	metrics.BreakerState.Set(float64(Closed), name)
+	return b
+}
+
+// State returns the current state of the breaker.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+	return b.state
+}
+
+// Do calls fn unless the breaker is open, and records whether it failed.
+// Errors for which ignore returns true, e.g. not found, count as successes.
+func (b *Breaker) Do(fn func() error, ignore ...func(error) bool) error {
+	if !b.allow() {
+		metrics.BreakerCalls.Inc(b.name, "rejected")
+		return ErrOpen
+	}
+
+	err := fn()
+	failed := err != nil
+	for _, ignored := range ignore {
+		if failed && ignored(err) {
+			failed = false
+		}
+	}
+	b.record(failed)
+	return err
+}
+
+// allow reports whether a call may go through, reserving the trial call
+// when half open.
+func (b *Breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.advance()
+
+	switch b.state {
+	case Closed:
+		return true
+	case HalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return false
+	}
+}
+
+// advance moves an open breaker to half open once the timeout has passed.
+func (b *Breaker) advance() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.openTimeout {
+		b.setState(HalfOpen)
+	}
+}
+
+// record updates the state with the outcome of a call.
+func (b *Breaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		metrics.BreakerCalls.Inc(b.name, "success")
+		b.failures = 0
+		b.trial = false
+		b.setState(Closed)
+		return
+	}
+
+	metrics.BreakerCalls.Inc(b.name, "failure")
+	b.failures++
+	b.trial = false
+	if b.state == HalfOpen || b.failures >= b.threshold {
+		b.openedAt = b.now()
+		b.setState(Open)
+	}
+}
+
+func (b *Breaker) setState(state State) {
+	b.state = state
+	metrics.BreakerState.Set(float64(state), b.name)
+}
@@ -0,0 +1,63 @@
+package breaker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBreaker(t *testing.T) {
+	now := time.Now()
+	b := New("test", 2, time.Minute)
+	b.now = func() time.Time { return now }
+
+	failure := errors.New("failure")
+	fail := func() error { return failure }
+	succeed := func() error { return nil }
+
+	b.Do(fail)
+	if b.State() != Closed {
+		t.Errorf("Expected closed after 1 failure, got %s", b.State())
+	}
+	b.Do(fail)
+	if b.State() != Open {
+		t.Errorf("Expected open after 2 failures, got %s", b.State())
+	}
+
+	called := false
+	if err := b.Do(func() error { called = true; return nil }); !errors.Is(err, ErrOpen) || called {
+		t.Errorf("Expected ErrOpen without calling, got %v and called %v", err, called)
+	}
+
+	now = now.Add(time.Minute)
+	if b.State() != HalfOpen {
+		t.Errorf("Expected half open after the timeout, got %s", b.State())
+	}
+	if err := b.Do(fail); !errors.Is(err, failure) {
+		t.Errorf("Expected the trial call to run, got %v", err)
+	}
+	if b.State() != Open {
+		t.Errorf("Expected open after a failed trial, got %s", b.State())
+	}
+
+	now = now.Add(time.Minute)
+	if err := b.Do(succeed); err != nil {
+		t.Errorf("Expected the trial call to succeed, got %v", err)
+	}
+	if b.State() != Closed {
+		t.Errorf("Expected closed after a successful trial, got %s", b.State())
+	}
+}
+
+func TestBreakerIgnoredErrors(t *testing.T) {
+	b := New("test_ignored", 1, time.Minute)
+	notFound := errors.New("not found")
+
+	err := b.Do(func() error { return notFound }, func(err error) bool { return errors.Is(err, notFound) })
+	if !errors.Is(err, notFound) {
+		t.Errorf("Expected the error to be returned, got %v", err)
+	}
+	if b.State() != Closed {
+		t.Errorf("Expected ignored errors not to open the breaker, got %s", b.State())
+	}
+}
@@ -7,3 +7,24 @@ var Currencies = []string{
 	"NZD",
 	"BTC",
 }
+
+// Decimals is the number of decimal places of the smallest unit of each
+// currency. Event amounts are in that unit, e.g. cents for EUR and satoshi
+// for BTC, and amounts in EUR are in euro cents.
+var Decimals = map[string]int{
+	"EUR": 2,
+	"USD": 2,
+	"GBP": 2,
+	"NZD": 2,
+	"BTC": 8,
+}
+
+// IsCurrency reports whether the currency is one of Currencies.
+func IsCurrency(currency string) bool {
+	for _, c := range Currencies {
+		if c == currency {
+			return true
+		}
+	}
+	return false
+}
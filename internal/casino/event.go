@@ -29,9 +29,17 @@ type Event struct {
 	// Only for type `bet`.
 	HasWon bool `json:"has_won,omitempty"`
 
+	// Payout of a winning bet, in the smallest unit of `Currency`.
+	// Only for type `bet` with `has_won`.
+	WinAmount int `json:"win_amount,omitempty"`
+
 	CreatedAt time.Time `json:"created_at"`
 
-	AmountEUR   int    `json:"amount_eur,omitempty"`
-	Player      Player `json:"player,omitempty"`
-	Description string `json:"description"`
+	AmountEUR    int    `json:"amount_eur,omitempty"`
+	WinAmountEUR int    `json:"win_amount_eur,omitempty"`
+	Player       Player `json:"player,omitempty"`
+	Description  string `json:"description"`
+
+	// Set by the processor when the event breaches a responsible gambling limit.
+	LimitBreached bool `json:"limit_breached,omitempty"`
 }
@@ -1,18 +1,31 @@
 package casino
 
+// Game categories, used to weight bets towards bonus wagering.
+const (
+	CategorySlots   = "slots"
+	CategoryJackpot = "jackpot"
+	CategoryDice    = "dice"
+)
+
 var Games = map[int]Game{
-	100: {Title: "Rocket Dice"},
-	101: {Title: "It's bananas!"},
-	102: {Title: "Wild Spin"},
-	103: {Title: "Book of Dead"},
-	104: {Title: "Pirate Jackpots"},
-	105: {Title: "Western Gold 2"},
-	106: {Title: "Super Rainbow Megaways"},
-	107: {Title: "#BarsAndBells"},
-	108: {Title: "Fortune Three"},
-	109: {Title: "ChilliPop"},
+	100: {Title: "Rocket Dice", RTP: 0.99, Category: CategoryDice},
+	101: {Title: "It's bananas!", RTP: 0.96, Category: CategorySlots},
+	102: {Title: "Wild Spin", RTP: 0.9635, Category: CategorySlots},
+	103: {Title: "Book of Dead", RTP: 0.9621, Category: CategorySlots},
+	104: {Title: "Pirate Jackpots", RTP: 0.955, Category: CategoryJackpot},
+	105: {Title: "Western Gold 2", RTP: 0.96, Category: CategorySlots},
+	106: {Title: "Super Rainbow Megaways", RTP: 0.9609, Category: CategorySlots},
+	107: {Title: "#BarsAndBells", RTP: 0.965, Category: CategorySlots},
+	108: {Title: "Fortune Three", RTP: 0.9597, Category: CategorySlots},
+	109: {Title: "ChilliPop", RTP: 0.9625, Category: CategorySlots},
 }
 
 type Game struct {
 	Title string
+
+	// Return to player: the share of wagered amounts paid back as wins on average.
+	RTP float64
+
+	// Category groups games for bonus wagering, e.g. "slots".
+	Category string
 }
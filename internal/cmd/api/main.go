@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/service"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	// Load configuration from the config file, environment variables and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid configuration")
+		os.Exit(2)
+	}
+	log.Info().Object("config", cfg).Msg("Configuration loaded")
+
+	api, err := service.NewAPI(cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialize API")
+		os.Exit(1)
+	}
+	defer api.Close()
+
+	// Serve the state of the processor from the shared snapshot store
+	api.Run(context.Background())
+}
@@ -2,103 +2,76 @@ package main
 
 import (
 	"context"
-	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
 	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
-	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
-	"github.com/Bitstarz-eng/event-processing-challenge/internal/enrichment"
-	"github.com/Bitstarz-eng/event-processing-challenge/internal/generator"
-	"github.com/Bitstarz-eng/event-processing-challenge/internal/materialize"
-	"github.com/Bitstarz-eng/event-processing-challenge/internal/pubsub"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/health"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/service"
 	"github.com/rs/zerolog/log"
-	"sync"
 )
 
+// main runs the producer, processor and API in one process. Use
+// cmd/producer, cmd/processor and cmd/api to scale them independently.
 func main() {
-	// Load configuration from environment variables
-	config.LoadConfig()
-	log.Info().Msgf("RabbitMQ URL: %s", config.RabbitMQURL)
-	log.Info().Msgf("Exchange Rate API: %s", config.ExchangeRateAPI)
-	log.Info().Msgf("Database URL: %s", config.DatabaseURL)
-
-	/// Initialize the database connection pool
-	playerRepo, err := enrichment.NewPlayerRepository()
+	// Load configuration from the config file, environment variables and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		log.Error().Err(err).Msg("Failed to initialize player repository")
-		return
+		log.Error().Err(err).Msg("Invalid configuration")
+		os.Exit(2)
 	}
-	defer playerRepo.Close()
+	log.Info().Object("config", cfg).Msg("Configuration loaded")
 
-	// Create a context to manage event generation
-	ctx := context.Background()
+	// Create a context to manage event generation, done on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Set up a wait group for goroutines
 	var wg sync.WaitGroup
 
-	// Create an instance of Materializer
-	materializer := materialize.NewMaterialize()
+	processor, err := service.NewProcessor(ctx, cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialize processor")
+		return
+	}
+	defer processor.Close()
 
-	// Start HTTP server in a separate goroutine
-	go materializer.StartHTTPServer()
+	producer, err := service.NewProducer(ctx, cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialize producer")
+		return
+	}
+	defer producer.Close()
 
-	// Start event generation
-	eventCh := generator.Generate(ctx)
+	// Start HTTP server with the health checks of both in a separate goroutine
+	checker := health.NewChecker(cfg.HealthTimeout)
+	producer.Health(checker)
+	processor.Health(checker)
+	go service.Serve(processor.Materializer, checker, processor.Routes())
 
 	// Publish generated events to RabbitMQ
 	wg.Add(1)
-	go publishGeneratedEvents(eventCh, playerRepo, &wg)
+	go func() {
+		defer wg.Done()
+		producer.Run(ctx)
+	}()
 
 	// Subscribe to processed events
 	wg.Add(1)
-	go subscribeToProcessedEvents(materializer, &wg)
+	go func() {
+		defer wg.Done()
+		if err := processor.Run(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to subscribe to events")
+		}
+	}()
 
-	// Wait for all goroutines to finish
+	// Wait for all goroutines to finish, and for the final snapshot
 	wg.Wait()
+	stop()
+	processor.Wait()
 
 	log.Info().Msg("All services stopped. Exiting...")
 }
-
-// Publish generated events
-func publishGeneratedEvents(eventCh <-chan casino.Event, playerRepo *enrichment.PlayerRepository, wg *sync.WaitGroup) {
-	defer wg.Done()
-	for event := range eventCh {
-		// Convert amount to EUR
-		event.AmountEUR = enrichment.ConvertToEUR(event.Amount, event.Currency)
-
-		// Fetch player data using the shared DB connection
-		player, err := playerRepo.FetchPlayer(event.PlayerID)
-		if err != nil {
-			log.Error().Err(err).Msgf("Failed to fetch player %d", event.PlayerID)
-		} else {
-			event.Player = player
-		}
-
-		// Check if player data is missing using IsZero()
-		if event.Player.IsZero() {
-			log.Warn().Msgf("Player %d not found, leaving player field empty.", event.PlayerID)
-		}
-
-		// Generate human-friendly description
-		event.Description = enrichment.GenerateDescription(event)
-
-		// Publish event to RabbitMQ
-		err = pubsub.PublishEvent(event)
-		if err != nil {
-			log.Error().Err(err).Msg("Failed to publish event")
-		}
-	}
-}
-
-// Subscribe to processed events
-func subscribeToProcessedEvents(materializer *materialize.Materialize, wg *sync.WaitGroup) {
-	defer wg.Done()
-	err := pubsub.SubscribeEvents(func(event casino.Event) {
-		// Update materialized stats
-		materializer.UpdateStats(event)
-		eventJSON, _ := json.Marshal(event)
-		log.Info().Msgf("Processed Event: %s", string(eventJSON))
-	})
-
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to subscribe to events")
-	}
-}
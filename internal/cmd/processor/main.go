@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/health"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/service"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	// Load configuration from the config file, environment variables and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid configuration")
+		os.Exit(2)
+	}
+	log.Info().Object("config", cfg).Msg("Configuration loaded")
+
+	// Stop consuming on SIGINT or SIGTERM and save a final snapshot
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	processor, err := service.NewProcessor(ctx, cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialize processor")
+		os.Exit(1)
+	}
+	defer processor.Close()
+
+	// Serve health checks and consumer and materialized metrics
+	checker := health.NewChecker(cfg.HealthTimeout)
+	processor.Health(checker)
+	go service.ServeMetrics(cfg.MetricsAddr, checker, processor.Materializer)
+
+	// Consume events from RabbitMQ
+	if err := processor.Run(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to subscribe to events")
+	}
+	stop()
+	processor.Wait()
+
+	log.Info().Msg("Processor stopped. Exiting...")
+}
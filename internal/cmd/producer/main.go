@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/health"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/service"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	// Load configuration from the config file, environment variables and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid configuration")
+		os.Exit(2)
+	}
+	log.Info().Object("config", cfg).Msg("Configuration loaded")
+
+	// Stop generating on SIGINT or SIGTERM and close the publisher
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	producer, err := service.NewProducer(ctx, cfg)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to initialize producer")
+		os.Exit(1)
+	}
+	defer producer.Close()
+
+	// Serve health checks and generator and enrichment metrics
+	checker := health.NewChecker(cfg.HealthTimeout)
+	producer.Health(checker)
+	go service.ServeMetrics(cfg.MetricsAddr, checker)
+
+	// Generate, enrich and publish events to RabbitMQ
+	producer.Run(ctx)
+
+	log.Info().Msg("Producer stopped. Exiting...")
+}
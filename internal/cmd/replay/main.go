@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/materialize"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/pubsub"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/replay"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+func main() {
+	source := flag.String("source", "postgres", "where to read events from: postgres or jsonl")
+	files := flag.String("files", "", "comma-separated JSONL files, for -source=jsonl")
+	from := flag.String("from", "", "replay events created at or after this RFC 3339 time")
+	to := flag.String("to", "", "replay events created before this RFC 3339 time")
+	target := flag.String("target", "materialize", "where to send events: materialize or broker")
+	speed := flag.Float64("speed", 0, "replay speed relative to the original timing, 0 for as fast as possible")
+	snapshot := flag.String("snapshot", "", "file to write the rebuilt materialized state to, for -target=materialize")
+
+	// Load configuration from the config file, environment variables and flags
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid configuration")
+		os.Exit(2)
+	}
+
+	fromTime, err := parseTime(*from)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid -from")
+		os.Exit(2)
+	}
+	toTime, err := parseTime(*to)
+	if err != nil {
+		log.Error().Err(err).Msg("Invalid -to")
+		os.Exit(2)
+	}
+
+	// Stop replaying on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// Set up the event source
+	var src replay.Source
+	switch *source {
+	case "postgres":
+		eventRepo, err := store.NewEventRepository(cfg.DatabaseURL)
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to initialize event store")
+			os.Exit(1)
+		}
+		defer eventRepo.Close()
+		src = eventRepo
+	case "jsonl":
+		if *files == "" {
+			log.Error().Msg("-files is required for -source=jsonl")
+			os.Exit(2)
+		}
+		src = replay.JSONLSource{Paths: strings.Split(*files, ",")}
+	default:
+		log.Error().Msgf("Unknown source %q", *source)
+		os.Exit(2)
+	}
+
+	// Set up the target
+	var materializer *materialize.Materialize
+	var handle func(casino.Event) error
+	switch *target {
+	case "materialize":
+		materializer = materialize.NewMaterialize()
+		handle = func(event casino.Event) error {
+			materializer.UpdateStats(event)
+			return nil
+		}
+	case "broker":
+		publisher := pubsub.NewPublisher(cfg.RabbitMQURL)
+		defer publisher.Close()
+		handle = publisher.PublishEvent
+	default:
+		log.Error().Msgf("Unknown target %q", *target)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	count, err := replay.NewReplayer(*speed).Replay(ctx, src, fromTime, toTime, handle)
+	if err != nil {
+		log.Error().Err(err).Msgf("Replay stopped after %d events", count)
+		os.Exit(1)
+	}
+	log.Info().Msgf("Replayed %d events in %s", count, time.Since(start))
+
+	if materializer == nil {
+		return
+	}
+
+	// Output the rebuilt stats and optionally save them as a snapshot
+	stats := materializer.QueryStats(materialize.Query{})
+	statsJSON, _ := json.Marshal(stats)
+	log.Info().Msgf("Materialized: %s", string(statsJSON))
+
+	if *snapshot != "" {
+		err = materializer.SaveSnapshot(materialize.NewFileSnapshotStore(*snapshot))
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to save snapshot")
+			os.Exit(1)
+		}
+		log.Info().Msgf("Snapshot written to %s", *snapshot)
+	}
+}
+
+// parseTime parses an optional RFC 3339 time
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -2,46 +2,106 @@
 package enrichment
 
 import (
+	"context"
 	"encoding/json"
-	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
-	"github.com/rs/zerolog/log"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/breaker"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+	"github.com/rs/zerolog/log"
 )
 
-// Hardcoded exchange rates as a fallback (different currencies to EUR)
+// Hardcoded exchange rates as a fallback, in units of the currency per EUR
+// like the rates of the API
 var fallbackRates = map[string]float64{
 	"EUR": 1.0,
-	"USD": 0.85,
-	"GBP": 1.15,
-	"NZD": 0.60,
-	"BTC": 30000.0, // Example rate
+	"USD": 1 / 0.85,
+	"GBP": 1 / 1.15,
+	"NZD": 1 / 0.60,
+	"BTC": 1 / 30000.0, // Example rate
 }
 
-// Cache to store exchange rates (valid for 1 minute)
-var cache struct {
-	sync.Mutex
+// ExchangeRates caches conversion rates refreshed from the API in the
+// background, so conversions never wait for the network. Until the first
+// successful refresh, the fallback rates are used.
+type ExchangeRates struct {
+	apiURL  string
+	client  *http.Client
+	breaker *breaker.Breaker
+
+	mu        sync.RWMutex
 	rates     map[string]float64
 	timestamp time.Time
 }
 
-// FetchExchangeRates retrieves conversion rates from API
-func FetchExchangeRates() (map[string]float64, error) {
-	cache.Lock()
-	defer cache.Unlock()
+// NewExchangeRates creates a new ExchangeRates fetching rates from the API
+// URL. Requests time out after timeout and go through the breaker.
+func NewExchangeRates(apiURL string, timeout time.Duration, breaker *breaker.Breaker) *ExchangeRates {
+	return &ExchangeRates{
+		apiURL:  apiURL,
+		client:  &http.Client{Timeout: timeout},
+		breaker: breaker,
+	}
+}
+
+// Run refreshes the rates immediately and then every interval until ctx is done.
+func (e *ExchangeRates) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := e.Refresh(ctx); err != nil {
+			log.Warn().Err(err).Msg("Failed to refresh exchange rates, keeping the current rates")
+		}
 
-	// Use cached data if less than 1 min old
-	if time.Since(cache.timestamp) < time.Minute {
-		return cache.rates, nil
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 	}
+}
 
-	resp, err := http.Get(config.ExchangeRateAPI)
+// Refresh fetches the rates from the API and caches them.
+func (e *ExchangeRates) Refresh(ctx context.Context) error {
+	var rates map[string]float64
+	err := e.breaker.Do(func() (err error) {
+		rates, err = e.fetch(ctx)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rates = rates
+	e.timestamp = time.Now()
+	return nil
+}
+
+// fetch retrieves conversion rates from API
+func (e *ExchangeRates) fetch(ctx context.Context) (map[string]float64, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.apiURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := e.client.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange rate API responded with status %d", resp.StatusCode)
+	}
+
 	var result struct {
 		Rates   map[string]float64 `json:"rates"`
 		Success bool               `json:"success"`
@@ -49,35 +109,49 @@ func FetchExchangeRates() (map[string]float64, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, err
 	}
+	if !result.Success || len(result.Rates) == 0 {
+		return nil, errors.New("exchange rate API reported a failure")
+	}
+	return result.Rates, nil
+}
 
-	if !result.Success {
-		log.Info().Msg("Failed to fetch exchange rates, using fallback rates")
-		cache.rates = fallbackRates
-		cache.timestamp = time.Now()
+// Rates returns the cached rates, or the fallback rates if none were fetched yet.
+func (e *ExchangeRates) Rates() map[string]float64 {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
 
-		return result.Rates, nil
+	if e.rates == nil {
+		metrics.ExchangeRateCache.Inc("miss")
+		return fallbackRates
 	}
+	metrics.ExchangeRateCache.Inc("hit")
+	return e.rates
+}
 
-	// Update cache
-	cache.rates = result.Rates
-	cache.timestamp = time.Now()
-
-	return result.Rates, nil
+// Updated returns when the rates were last refreshed, or the zero time if
+// they never were and the fallback rates are used.
+func (e *ExchangeRates) Updated() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.timestamp
 }
 
 // ConvertToEUR converts given amount to EUR
-func ConvertToEUR(amount int, currency string) int {
-	rates, err := FetchExchangeRates()
-	if err != nil {
-		log.Error().Err(err).Msg("Failed to fetch exchange rates, returning original amount")
-		return amount
-	}
+func (e *ExchangeRates) ConvertToEUR(amount int, currency string) int {
+	return ConvertToEURWithRates(amount, currency, e.Rates())
+}
 
+// ConvertToEURWithRates converts given amount to EUR using the given rates.
+// The amount is in the smallest unit of the currency, see casino.Decimals,
+// the rates are in units of the currency per EUR, and the result is in euro cents.
+func ConvertToEURWithRates(amount int, currency string, rates map[string]float64) int {
 	rate, exists := rates[currency]
-	if !exists {
+	decimals, known := casino.Decimals[currency]
+	if !exists || !known {
 		log.Warn().Str("currency", currency).Msg("Unknown currency")
 		return amount
 	}
 
-	return int(float64(amount) / rate)
+	units := float64(amount) / math.Pow10(decimals)
+	return int(math.Round(units / rate * math.Pow10(casino.Decimals["EUR"])))
 }
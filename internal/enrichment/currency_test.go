@@ -0,0 +1,122 @@
+package enrichment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/breaker"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+func TestExchangeRatesRefresh(t *testing.T) {
+	var fail int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&fail) == 1 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"success": true, "rates": {"EUR": 1, "USD": 2}}`))
+	}))
+	defer server.Close()
+
+	rates := NewExchangeRates(server.URL, time.Second, breaker.New("test_rates", 5, time.Minute))
+	if got := rates.ConvertToEUR(200, "USD"); got != 170 {
+		t.Errorf("Expected fallback conversion 170, got %d", got)
+	}
+
+	if err := rates.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := rates.ConvertToEUR(200, "USD"); got != 100 {
+		t.Errorf("Expected 100 EUR, got %d", got)
+	}
+	updated := rates.Updated()
+
+	atomic.StoreInt32(&fail, 1)
+	if err := rates.Refresh(context.Background()); err == nil {
+		t.Error("Expected refresh to fail")
+	}
+	if got := rates.ConvertToEUR(200, "USD"); got != 100 || rates.Updated() != updated {
+		t.Errorf("Expected the last rates to be kept, got %d EUR", got)
+	}
+}
+
+func TestConvertToEURWithRates(t *testing.T) {
+	rates := map[string]float64{"EUR": 1, "USD": 1.068, "BTC": 0.00002}
+
+	tests := []struct {
+		amount   int
+		currency string
+		expected int
+	}{
+		{500, "EUR", 500},
+		{500, "USD", 468},
+		// 724140 satoshi is 0.0072414 BTC, or 362.07 EUR at 50000 EUR per BTC.
+		{724140, "BTC", 36207},
+	}
+	for _, tt := range tests {
+		if got := ConvertToEURWithRates(tt.amount, tt.currency, rates); got != tt.expected {
+			t.Errorf("Expected %d %s to be %d EUR cents, got %d", tt.amount, tt.currency, tt.expected, got)
+		}
+	}
+}
+
+func TestConvertToEURWithFallbackRates(t *testing.T) {
+	for _, currency := range casino.Currencies {
+		if _, ok := fallbackRates[currency]; !ok {
+			t.Errorf("Missing fallback rate for %s", currency)
+		}
+		if _, ok := casino.Decimals[currency]; !ok {
+			t.Errorf("Missing decimals for %s", currency)
+		}
+	}
+
+	tests := []struct {
+		amount   int
+		currency string
+		expected int
+	}{
+		{500, "EUR", 500},
+		// 1 GBP is 1.15 EUR.
+		{1000, "GBP", 1150},
+		// 20000 satoshi is 0.0002 BTC, or 6 EUR at 30000 EUR per BTC.
+		{20000, "BTC", 600},
+		// Unknown currencies are kept as they are.
+		{500, "XYZ", 500},
+	}
+	for _, tt := range tests {
+		if got := ConvertToEURWithRates(tt.amount, tt.currency, fallbackRates); got != tt.expected {
+			t.Errorf("Expected %d %s to be %d EUR cents, got %d", tt.amount, tt.currency, tt.expected, got)
+		}
+	}
+}
+
+func TestExchangeRatesTimeoutAndBreaker(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	rates := NewExchangeRates(server.URL, 20*time.Millisecond, breaker.New("test_rates_timeout", 2, time.Minute))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		rates.Refresh(context.Background())
+	}
+	if time.Since(start) > 150*time.Millisecond {
+		t.Errorf("Expected requests to time out, took %s", time.Since(start))
+	}
+	if err := rates.Refresh(context.Background()); !errors.Is(err, breaker.ErrOpen) {
+		t.Errorf("Expected ErrOpen, got %v", err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 2 {
+		t.Errorf("Expected 2 requests before the breaker opened, got %d", n)
+	}
+}
@@ -17,6 +17,11 @@ func GenerateDescription(event casino.Event) string {
 		return fmt.Sprintf("Player #%d started playing a game \"%s\" on %s.",
 			event.PlayerID, gameTitle, timestamp)
 	case "bet":
+		if event.HasWon {
+			return fmt.Sprintf("Player #%d placed a bet of %d %s (%d EUR) on \"%s\" and won %d %s (%d EUR) on %s.",
+				event.PlayerID, event.Amount, event.Currency, event.AmountEUR, gameTitle,
+				event.WinAmount, event.Currency, event.WinAmountEUR, timestamp)
+		}
 		return fmt.Sprintf("Player #%d placed a bet of %d %s (%d EUR) on \"%s\" on %s.",
 			event.PlayerID, event.Amount, event.Currency, event.AmountEUR, gameTitle, timestamp)
 	case "deposit":
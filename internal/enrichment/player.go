@@ -2,30 +2,36 @@
 package enrichment
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/breaker"
 	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
 	_ "github.com/lib/pq" // PostgreSQL driver
 	"github.com/rs/zerolog/log"
+	"time"
 )
 
 // PlayerRepository handles database operations related to players.
 type PlayerRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout time.Duration
+	breaker *breaker.Breaker
 }
 
-// NewPlayerRepository creates a new PlayerRepository instance.
-func NewPlayerRepository() (*PlayerRepository, error) {
-	db, err := sql.Open("postgres", config.DatabaseURL)
+// NewPlayerRepository creates a new PlayerRepository instance. Lookups time
+// out after timeout and go through the breaker.
+func NewPlayerRepository(databaseURL string, timeout time.Duration, breaker *breaker.Breaker) (*PlayerRepository, error) {
+	db, err := sql.Open("postgres", databaseURL)
 	if err != nil {
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
-	return &PlayerRepository{db: db}, nil
+	return &PlayerRepository{db: db, timeout: timeout, breaker: breaker}, nil
 }
 
 // Close closes the database connection.
@@ -33,11 +39,28 @@ func (r *PlayerRepository) Close() error {
 	return r.db.Close()
 }
 
+// Ping checks the database connection.
+func (r *PlayerRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
 // FetchPlayer retrieves player info using an existing database connection.
-func (r *PlayerRepository) FetchPlayer(playerID int) (casino.Player, error) {
+// While the breaker is open it fails fast with breaker.ErrOpen.
+func (r *PlayerRepository) FetchPlayer(ctx context.Context, playerID int) (casino.Player, error) {
+	defer func(start time.Time) {
+		metrics.DBLookupDuration.Observe(time.Since(start).Seconds())
+	}(time.Now())
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
 	var player casino.Player
 	query := `SELECT email, last_signed_in_at FROM players WHERE id = $1`
-	err := r.db.QueryRow(query, playerID).Scan(&player.Email, &player.LastSignedInAt)
+	err := r.breaker.Do(func() error {
+		return r.db.QueryRowContext(ctx, query, playerID).Scan(&player.Email, &player.LastSignedInAt)
+	}, func(err error) bool {
+		return errors.Is(err, sql.ErrNoRows)
+	})
 
 	if errors.Is(err, sql.ErrNoRows) {
 		log.Warn().Msgf("Player %d not found in database", playerID)
@@ -0,0 +1,300 @@
+// Package fraud This evaluates anomaly rules against consumed events and raises alerts.
+package fraud
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// Queue is the RabbitMQ queue alerts are published to.
+const Queue = "casino_alerts"
+
+// Rule names.
+const (
+	RuleDepositVelocity = "deposit_velocity"
+	RuleBetJump         = "bet_jump"
+	RuleManyCurrencies  = "many_currencies"
+	RuleWinsAboveRTP    = "wins_above_rtp"
+)
+
+// Alert is raised when an event breaks a rule.
+type Alert struct {
+	ID             int64     `json:"id,omitempty"`
+	Rule           string    `json:"rule"`
+	PlayerID       int       `json:"player_id"`
+	EventID        int       `json:"event_id"`
+	EventCreatedAt time.Time `json:"event_created_at"`
+	Value          float64   `json:"value"`
+	Threshold      float64   `json:"threshold"`
+	Message        string    `json:"message"`
+}
+
+// Rules configures the thresholds of the rules. A rule is disabled by
+// setting its main threshold to zero.
+type Rules struct {
+	// MaxDeposits is the number of deposits allowed within DepositWindow.
+	MaxDeposits   int
+	DepositWindow time.Duration
+
+	// BetJumpFactor is how many times a bet may exceed the average bet of
+	// the player, once the player has placed BetJumpMinBets bets.
+	BetJumpFactor  float64
+	BetJumpMinBets int
+
+	// MaxCurrencies is the number of currencies a player may use within CurrencyWindow.
+	MaxCurrencies  int
+	CurrencyWindow time.Duration
+
+	// RTPMargin is how far the return of a player over RTPWindow may exceed
+	// the RTP of the games played, once the player has placed RTPMinBets
+	// bets, e.g. 1 allows winning up to RTP + 100% of the wagered amount.
+	RTPMargin  float64
+	RTPMinBets int
+	RTPWindow  time.Duration
+
+	// Cooldown suppresses repeated alerts of the same rule for a player.
+	Cooldown time.Duration
+}
+
+// DefaultRules returns rules enabling every check.
+func DefaultRules() Rules {
+	return Rules{
+		MaxDeposits:    4,
+		DepositWindow:  time.Minute,
+		BetJumpFactor:  10,
+		BetJumpMinBets: 5,
+		MaxCurrencies:  2,
+		CurrencyWindow: 24 * time.Hour,
+		RTPMargin:      2,
+		RTPMinBets:     20,
+		RTPWindow:      24 * time.Hour,
+		Cooldown:       10 * time.Minute,
+	}
+}
+
+// Engine evaluates the rules against the events of each player. Windows are
+// measured in event time, so replayed events raise the same alerts. They end
+// at the newest event of the player, so an event arriving late is counted in
+// the windows it falls in instead of restarting them.
+type Engine struct {
+	rules Rules
+
+	mu      sync.Mutex
+	players map[int]*playerState
+}
+
+// playerState is what the rules remember about a player. The times and bets
+// are sorted by event time.
+type playerState struct {
+	latest     time.Time
+	seen       map[eventKey]time.Time
+	pruneSeen  int
+	deposits   []time.Time
+	bets       int
+	betSumEUR  int
+	currencies map[string]time.Time
+	recentBets []bet
+	lastAlerts map[string]time.Time
+}
+
+// eventKey identifies an event, like the unique keys of the stores.
+type eventKey struct {
+	id        int
+	createdAt time.Time
+}
+
+// bet is a bet within the RTP window.
+type bet struct {
+	at        time.Time
+	amountEUR int
+	winEUR    int
+	rtp       float64
+}
+
+// NewEngine creates a new Engine.
+func NewEngine(rules Rules) *Engine {
+	return &Engine{rules: rules, players: make(map[int]*playerState)}
+}
+
+// Horizon is how far back in event time the state of a player reaches: the
+// longest window or cooldown. Replaying the events of the horizon before the
+// newest one rebuilds the windows, e.g. after a restart.
+func (e *Engine) Horizon() time.Duration {
+	horizon := e.rules.Cooldown
+	for _, window := range []time.Duration{e.rules.DepositWindow, e.rules.CurrencyWindow, e.rules.RTPWindow} {
+		if window > horizon {
+			horizon = window
+		}
+	}
+	return horizon
+}
+
+// Evaluate applies the event to the state of its player and returns the
+// alerts it raises. Events already evaluated, e.g. redelivered ones, are
+// skipped. So are events older than the horizon before the newest event of
+// the player, which can no longer be told apart from redeliveries.
+func (e *Engine) Evaluate(event casino.Event) []Alert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	p, ok := e.players[event.PlayerID]
+	if !ok {
+		p = &playerState{
+			seen:       make(map[eventKey]time.Time),
+			currencies: make(map[string]time.Time),
+			lastAlerts: make(map[string]time.Time),
+		}
+		e.players[event.PlayerID] = p
+	}
+
+	key := eventKey{event.ID, event.CreatedAt.UTC()}
+	if _, seen := p.seen[key]; seen || p.latest.Sub(event.CreatedAt) > e.Horizon() {
+		return nil
+	}
+	p.seen[key] = event.CreatedAt
+	if event.CreatedAt.After(p.latest) {
+		p.latest = event.CreatedAt
+	}
+	// Forget events past the horizon whenever the set has doubled, which
+	// keeps the cost per event constant
+	if len(p.seen) > p.pruneSeen {
+		for key, at := range p.seen {
+			if p.latest.Sub(at) > e.Horizon() {
+				delete(p.seen, key)
+			}
+		}
+		p.pruneSeen = 2*len(p.seen) + 16
+	}
+
+	var alerts []Alert
+	raise := func(rule string, value, threshold float64, format string, args ...any) {
+		if last, ok := p.lastAlerts[rule]; ok && absDuration(event.CreatedAt.Sub(last)) < e.rules.Cooldown {
+			return
+		}
+		if event.CreatedAt.After(p.lastAlerts[rule]) {
+			p.lastAlerts[rule] = event.CreatedAt
+		}
+		alerts = append(alerts, Alert{
+			Rule:           rule,
+			PlayerID:       event.PlayerID,
+			EventID:        event.ID,
+			EventCreatedAt: event.CreatedAt,
+			Value:          value,
+			Threshold:      threshold,
+			Message:        fmt.Sprintf("Player #%d "+format, append([]any{event.PlayerID}, args...)...),
+		})
+	}
+
+	if event.Currency != "" && e.rules.MaxCurrencies > 0 {
+		if event.CreatedAt.After(p.currencies[event.Currency]) {
+			p.currencies[event.Currency] = event.CreatedAt
+		}
+		for currency, at := range p.currencies {
+			if p.latest.Sub(at) > e.rules.CurrencyWindow {
+				delete(p.currencies, currency)
+			}
+		}
+		if len(p.currencies) > e.rules.MaxCurrencies {
+			raise(RuleManyCurrencies, float64(len(p.currencies)), float64(e.rules.MaxCurrencies),
+				"used %d currencies within %s", len(p.currencies), e.rules.CurrencyWindow)
+		}
+	}
+
+	switch event.Type {
+	case "deposit":
+		if e.rules.MaxDeposits > 0 {
+			p.deposits = prune(insertTime(p.deposits, event.CreatedAt), p.latest, e.rules.DepositWindow)
+			if len(p.deposits) > e.rules.MaxDeposits {
+				raise(RuleDepositVelocity, float64(len(p.deposits)), float64(e.rules.MaxDeposits),
+					"made %d deposits within %s", len(p.deposits), e.rules.DepositWindow)
+			}
+		}
+
+	case "bet":
+		if e.rules.BetJumpFactor > 0 && p.bets >= e.rules.BetJumpMinBets && p.betSumEUR > 0 {
+			average := float64(p.betSumEUR) / float64(p.bets)
+			if factor := float64(event.AmountEUR) / average; factor > e.rules.BetJumpFactor {
+				raise(RuleBetJump, factor, e.rules.BetJumpFactor,
+					"bet %d EUR, %.1f times the average bet of %.0f EUR", event.AmountEUR, factor, average)
+			}
+		}
+		p.bets++
+		p.betSumEUR += event.AmountEUR
+
+		if e.rules.RTPMargin > 0 {
+			p.recentBets = insertBet(p.recentBets, bet{
+				at:        event.CreatedAt,
+				amountEUR: event.AmountEUR,
+				winEUR:    event.WinAmountEUR,
+				rtp:       casino.Games[event.GameID].RTP,
+			})
+			for len(p.recentBets) > 0 && p.latest.Sub(p.recentBets[0].at) > e.rules.RTPWindow {
+				p.recentBets = p.recentBets[1:]
+			}
+			if len(p.recentBets) >= e.rules.RTPMinBets {
+				e.checkRTP(p, raise)
+			}
+		}
+	}
+
+	return alerts
+}
+
+// checkRTP raises an alert if the player won more than the RTP of the
+// played games, weighted by the wagered amounts, plus the margin.
+func (e *Engine) checkRTP(p *playerState, raise func(string, float64, float64, string, ...any)) {
+	var wagered, won int
+	var expected float64
+	for _, b := range p.recentBets {
+		wagered += b.amountEUR
+		won += b.winEUR
+		expected += float64(b.amountEUR) * b.rtp
+	}
+	if wagered == 0 {
+		return
+	}
+
+	observed := float64(won) / float64(wagered)
+	threshold := expected/float64(wagered) + e.rules.RTPMargin
+	if observed > threshold {
+		raise(RuleWinsAboveRTP, observed, threshold,
+			"returned %.0f%% over %d bets, above the %.0f%% threshold", observed*100, len(p.recentBets), threshold*100)
+	}
+}
+
+// prune drops the times older than the window before now.
+func prune(times []time.Time, now time.Time, window time.Duration) []time.Time {
+	for len(times) > 0 && now.Sub(times[0]) > window {
+		times = times[1:]
+	}
+	return times
+}
+
+// insertTime inserts t into the sorted times, after any equal ones.
+func insertTime(times []time.Time, t time.Time) []time.Time {
+	i := sort.Search(len(times), func(i int) bool { return times[i].After(t) })
+	times = append(times, time.Time{})
+	copy(times[i+1:], times[i:])
+	times[i] = t
+	return times
+}
+
+// insertBet inserts b into the bets sorted by time, after any equal ones.
+func insertBet(bets []bet, b bet) []bet {
+	i := sort.Search(len(bets), func(i int) bool { return bets[i].at.After(b.at) })
+	bets = append(bets, bet{})
+	copy(bets[i+1:], bets[i:])
+	bets[i] = b
+	return bets
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,202 @@
+package fraud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+var start = time.Date(2025, 2, 19, 12, 0, 0, 0, time.UTC)
+
+// evaluate feeds the events to the engine and returns the rules of the raised alerts.
+func evaluate(engine *Engine, events []casino.Event) []string {
+	var rules []string
+	for i, event := range events {
+		event.ID = i + 1
+		if event.PlayerID == 0 {
+			event.PlayerID = 10
+		}
+		for _, alert := range engine.Evaluate(event) {
+			rules = append(rules, alert.Rule)
+		}
+	}
+	return rules
+}
+
+func TestDepositVelocity(t *testing.T) {
+	var events []casino.Event
+	for i := 0; i < 5; i++ {
+		events = append(events, casino.Event{Type: "deposit", Currency: "EUR", AmountEUR: 100, CreatedAt: start.Add(time.Duration(i) * 10 * time.Second)})
+	}
+
+	rules := evaluate(NewEngine(DefaultRules()), events)
+	if len(rules) != 1 || rules[0] != RuleDepositVelocity {
+		t.Errorf("Expected a deposit velocity alert, got %v", rules)
+	}
+
+	// The same deposits spread over more than the window raise nothing.
+	for i := range events {
+		events[i].CreatedAt = start.Add(time.Duration(i) * time.Minute)
+	}
+	if rules := evaluate(NewEngine(DefaultRules()), events); len(rules) != 0 {
+		t.Errorf("Expected no alerts, got %v", rules)
+	}
+}
+
+func TestBetJump(t *testing.T) {
+	var events []casino.Event
+	for i := 0; i < 5; i++ {
+		events = append(events, casino.Event{Type: "bet", GameID: 100, Currency: "EUR", AmountEUR: 10, CreatedAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	events = append(events, casino.Event{Type: "bet", GameID: 100, Currency: "EUR", AmountEUR: 101, CreatedAt: start.Add(time.Minute)})
+
+	rules := evaluate(NewEngine(DefaultRules()), events)
+	if len(rules) != 1 || rules[0] != RuleBetJump {
+		t.Errorf("Expected a bet jump alert, got %v", rules)
+	}
+}
+
+func TestManyCurrencies(t *testing.T) {
+	events := []casino.Event{
+		{Type: "deposit", Currency: "EUR", CreatedAt: start},
+		{Type: "deposit", Currency: "USD", CreatedAt: start.Add(time.Hour)},
+		{Type: "deposit", Currency: "BTC", CreatedAt: start.Add(24*time.Hour + 30*time.Minute)},
+		{Type: "deposit", Currency: "GBP", CreatedAt: start.Add(24*time.Hour + 45*time.Minute)},
+	}
+
+	// EUR is out of the window by the time BTC is used.
+	rules := evaluate(NewEngine(DefaultRules()), events)
+	if len(rules) != 1 || rules[0] != RuleManyCurrencies {
+		t.Errorf("Expected a single many currencies alert, got %v", rules)
+	}
+}
+
+func TestWinsAboveRTP(t *testing.T) {
+	rules := DefaultRules()
+	rules.RTPMinBets = 3
+
+	var events []casino.Event
+	for i := 0; i < 3; i++ {
+		events = append(events, casino.Event{Type: "bet", GameID: 100, Currency: "EUR", AmountEUR: 10, HasWon: true, WinAmountEUR: 100,
+			CreatedAt: start.Add(time.Duration(i) * time.Second)})
+	}
+
+	got := evaluate(NewEngine(rules), events)
+	if len(got) != 1 || got[0] != RuleWinsAboveRTP {
+		t.Errorf("Expected a wins above RTP alert, got %v", got)
+	}
+}
+
+func TestCooldown(t *testing.T) {
+	engine := NewEngine(DefaultRules())
+	var events []casino.Event
+	for i := 0; i < 8; i++ {
+		events = append(events, casino.Event{Type: "deposit", Currency: "EUR", CreatedAt: start.Add(time.Duration(i) * time.Second)})
+	}
+	if rules := evaluate(engine, events); len(rules) != 1 {
+		t.Errorf("Expected 1 alert within the cooldown, got %v", rules)
+	}
+
+	for i := range events {
+		events[i].CreatedAt = start.Add(time.Hour + time.Duration(i)*time.Second)
+	}
+	if rules := evaluate(engine, events); len(rules) != 1 {
+		t.Errorf("Expected another alert after the cooldown, got %v", rules)
+	}
+}
+
+func TestSkipsSeenEvents(t *testing.T) {
+	engine := NewEngine(DefaultRules())
+	deposit := casino.Event{ID: 1, PlayerID: 10, Type: "deposit", Currency: "EUR", CreatedAt: start}
+
+	// A redelivered deposit counts once, so four deliveries stay within the limit.
+	for i := 0; i < 5; i++ {
+		if alerts := engine.Evaluate(deposit); len(alerts) != 0 {
+			t.Fatalf("Expected no alerts for delivery %d, got %v", i+1, alerts)
+		}
+	}
+	if n := len(engine.players[10].deposits); n != 1 {
+		t.Errorf("Expected 1 deposit in the window, got %d", n)
+	}
+}
+
+func TestOutOfOrderEvents(t *testing.T) {
+	engine := NewEngine(DefaultRules())
+	deposit := func(id int, at time.Duration) []Alert {
+		return engine.Evaluate(casino.Event{ID: id, PlayerID: 10, Type: "deposit", Currency: "EUR", CreatedAt: start.Add(at)})
+	}
+
+	// A deposit older than the window before the newest one isn't counted.
+	deposit(1, 2*time.Minute)
+	deposit(2, 0)
+	if n := len(engine.players[10].deposits); n != 1 {
+		t.Errorf("Expected the late deposit to be pruned, got %d deposits", n)
+	}
+
+	// Late deposits within the window are counted and kept in order.
+	deposit(3, 110*time.Second)
+	deposit(4, 100*time.Second)
+	alerts := deposit(5, 90*time.Second)
+	if len(alerts) != 0 {
+		t.Errorf("Expected no alerts with 4 deposits, got %v", alerts)
+	}
+	alerts = deposit(6, 95*time.Second)
+	if len(alerts) != 1 || alerts[0].Rule != RuleDepositVelocity {
+		t.Errorf("Expected a deposit velocity alert, got %v", alerts)
+	}
+	deposits := engine.players[10].deposits
+	for i := 1; i < len(deposits); i++ {
+		if deposits[i].Before(deposits[i-1]) {
+			t.Fatalf("Expected deposits sorted by time, got %v", deposits)
+		}
+	}
+
+	// A late event within the cooldown before the last alert is suppressed too.
+	if alerts := deposit(7, 85*time.Second); len(alerts) != 0 {
+		t.Errorf("Expected the cooldown to suppress a late alert, got %v", alerts)
+	}
+}
+
+func TestServeAlerts(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	for _, alert := range []Alert{
+		{Rule: RuleDepositVelocity, PlayerID: 10, EventID: 1, EventCreatedAt: start},
+		{Rule: RuleBetJump, PlayerID: 11, EventID: 2, EventCreatedAt: start},
+		{Rule: RuleBetJump, PlayerID: 10, EventID: 3, EventCreatedAt: start},
+	} {
+		if _, err := store.Add(ctx, alert); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := store.Add(ctx, Alert{Rule: RuleBetJump, PlayerID: 10, EventID: 3, EventCreatedAt: start}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	ServeAlerts(store)(rec, httptest.NewRequest(http.MethodGet, "/alerts?player_id=10&limit=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	var response struct {
+		Alerts []Alert `json:"alerts"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Alerts) != 1 || response.Alerts[0].EventID != 3 {
+		t.Errorf("Expected the newest alert of player 10, got %+v", response.Alerts)
+	}
+
+	rec = httptest.NewRecorder()
+	ServeAlerts(store)(rec, httptest.NewRequest(http.MethodGet, "/alerts?player_id=x", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an invalid player_id, got %d", rec.Code)
+	}
+}
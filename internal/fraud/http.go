@@ -0,0 +1,64 @@
+package fraud
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultListLimit is the number of alerts listed when no limit is given.
+const defaultListLimit = 50
+
+// ServeAlerts handles HTTP requests to list alerts, newest first. They can be
+// filtered with the player_id and rule query parameters, and their number set
+// with the limit query parameter.
+func ServeAlerts(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter := Filter{Rule: r.URL.Query().Get("rule")}
+		if v := r.URL.Query().Get("player_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				http.Error(w, "player_id must be an integer", http.StatusBadRequest)
+				return
+			}
+			filter.PlayerID = id
+		}
+		serveAlerts(w, r, store, filter)
+	}
+}
+
+// ServePlayerAlerts handles HTTP requests to list the alerts of a player.
+func ServePlayerAlerts(store Store) func(w http.ResponseWriter, r *http.Request, playerID int) {
+	return func(w http.ResponseWriter, r *http.Request, playerID int) {
+		serveAlerts(w, r, store, Filter{PlayerID: playerID, Rule: r.URL.Query().Get("rule")})
+	}
+}
+
+func serveAlerts(w http.ResponseWriter, r *http.Request, store Store, filter Filter) {
+	filter.Limit = defaultListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be an integer between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		filter.Limit = n
+	}
+
+	alerts, err := store.List(r.Context(), filter)
+	if err != nil {
+		http.Error(w, "failed to fetch alerts", http.StatusInternalServerError)
+		return
+	}
+	if alerts == nil {
+		alerts = []Alert{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(struct {
+		Alerts []Alert `json:"alerts"`
+	}{alerts})
+	if err != nil {
+		return
+	}
+}
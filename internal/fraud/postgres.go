@@ -0,0 +1,90 @@
+package fraud
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// PostgresStore keeps alerts in Postgres.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore.
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Close closes the database connection.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// Add stores the alert.
+func (s *PostgresStore) Add(ctx context.Context, alert Alert) (Alert, error) {
+	err := s.db.QueryRowContext(ctx, `
+		INSERT INTO fraud_alerts (rule, player_id, event_id, event_created_at, value, threshold, message)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (rule, event_id, event_created_at) DO NOTHING
+		RETURNING id`,
+		alert.Rule, alert.PlayerID, alert.EventID, alert.EventCreatedAt, alert.Value, alert.Threshold, alert.Message,
+	).Scan(&alert.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Alert{}, ErrDuplicate
+	} else if err != nil {
+		return Alert{}, fmt.Errorf("error storing alert: %w", err)
+	}
+	return alert, nil
+}
+
+// List returns the matching alerts, newest first.
+func (s *PostgresStore) List(ctx context.Context, filter Filter) ([]Alert, error) {
+	var conditions []string
+	var args []any
+	if filter.PlayerID != 0 {
+		args = append(args, filter.PlayerID)
+		conditions = append(conditions, fmt.Sprintf("player_id = $%d", len(args)))
+	}
+	if filter.Rule != "" {
+		args = append(args, filter.Rule)
+		conditions = append(conditions, fmt.Sprintf("rule = $%d", len(args)))
+	}
+
+	query := `SELECT id, rule, player_id, event_id, event_created_at, value, threshold, message FROM fraud_alerts`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching alerts: %w", err)
+	}
+	defer rows.Close()
+
+	var alerts []Alert
+	for rows.Next() {
+		var a Alert
+		if err := rows.Scan(&a.ID, &a.Rule, &a.PlayerID, &a.EventID, &a.EventCreatedAt, &a.Value, &a.Threshold, &a.Message); err != nil {
+			return nil, fmt.Errorf("error scanning alert: %w", err)
+		}
+		alerts = append(alerts, a)
+	}
+	return alerts, rows.Err()
+}
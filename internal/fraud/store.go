@@ -0,0 +1,80 @@
+package fraud
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrDuplicate is returned when the alert was already raised for the event,
+// e.g. because the event was redelivered.
+var ErrDuplicate = errors.New("alert already raised")
+
+// Filter selects alerts to list. Zero fields match every alert.
+type Filter struct {
+	PlayerID int
+	Rule     string
+	Limit    int
+}
+
+// Store keeps raised alerts.
+type Store interface {
+	// Add stores the alert and returns it with its ID.
+	Add(ctx context.Context, alert Alert) (Alert, error)
+	// List returns the matching alerts, newest first.
+	List(ctx context.Context, filter Filter) ([]Alert, error)
+}
+
+// MemoryStore keeps alerts in memory.
+type MemoryStore struct {
+	mu     sync.Mutex
+	alerts []Alert
+	raised map[alertKey]bool
+}
+
+type alertKey struct {
+	rule      string
+	eventID   int
+	createdAt time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{raised: make(map[alertKey]bool)}
+}
+
+// Add stores the alert.
+func (s *MemoryStore) Add(ctx context.Context, alert Alert) (Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := alertKey{alert.Rule, alert.EventID, alert.EventCreatedAt.UTC()}
+	if s.raised[key] {
+		return Alert{}, ErrDuplicate
+	}
+	s.raised[key] = true
+
+	alert.ID = int64(len(s.alerts) + 1)
+	s.alerts = append(s.alerts, alert)
+	return alert, nil
+}
+
+// List returns the matching alerts, newest first.
+func (s *MemoryStore) List(ctx context.Context, filter Filter) ([]Alert, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var alerts []Alert
+	for i := len(s.alerts) - 1; i >= 0; i-- {
+		alert := s.alerts[i]
+		if (filter.PlayerID != 0 && alert.PlayerID != filter.PlayerID) || (filter.Rule != "" && alert.Rule != filter.Rule) {
+			continue
+		}
+		alerts = append(alerts, alert)
+		if filter.Limit > 0 && len(alerts) == filter.Limit {
+			break
+		}
+	}
+	return alerts, nil
+}
@@ -0,0 +1,248 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// Fault is a kind of defect injected into the event stream.
+type Fault string
+
+// Faults injected by Chaos.
+const (
+	// FaultDuplicate publishes an event a second time with the same ID.
+	FaultDuplicate Fault = "duplicate"
+	// FaultOutOfOrder moves CreatedAt up to an hour into the past.
+	FaultOutOfOrder Fault = "out_of_order"
+	// FaultUnknownCurrency replaces the currency with one that has no exchange rate.
+	FaultUnknownCurrency Fault = "unknown_currency"
+	// FaultUnknownGame replaces the game ID with one not in casino.Games.
+	FaultUnknownGame Fault = "unknown_game"
+	// FaultUnknownPlayer replaces the player ID with one not in the players table.
+	FaultUnknownPlayer Fault = "unknown_player"
+	// FaultMissingFields clears a required field.
+	FaultMissingFields Fault = "missing_fields"
+	// FaultMalformed replaces the event with bytes that aren't a valid JSON event.
+	FaultMalformed Fault = "malformed"
+)
+
+// Faults lists every fault in the order they are applied.
+var Faults = []Fault{
+	FaultOutOfOrder,
+	FaultUnknownCurrency,
+	FaultUnknownGame,
+	FaultUnknownPlayer,
+	FaultMissingFields,
+	FaultMalformed,
+	FaultDuplicate,
+}
+
+// Values used for unknown references, chosen so they never collide with real ones.
+const (
+	UnknownCurrency = "XXX"
+	UnknownGameID   = 999
+	UnknownPlayerID = 999999
+)
+
+// ParseFaultRates parses comma-separated fault=rate pairs, e.g. "duplicate=0.01,malformed=0.001".
+func ParseFaultRates(s string) (map[Fault]float64, error) {
+	rates := make(map[Fault]float64)
+	if s == "" {
+		return rates, nil
+	}
+
+	known := make(map[Fault]bool, len(Faults))
+	for _, f := range Faults {
+		known[f] = true
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault rate %q, expected fault=rate", pair)
+		}
+		if !known[Fault(name)] {
+			return nil, fmt.Errorf("unknown fault %q", name)
+		}
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || rate < 0 || rate > 1 {
+			return nil, fmt.Errorf("invalid rate %q for fault %s, expected a number between 0 and 1", value, name)
+		}
+		rates[Fault(name)] = rate
+	}
+	return rates, nil
+}
+
+// Message is what gets published: an event, or raw bytes for malformed messages.
+type Message struct {
+	Event casino.Event
+	Raw   []byte
+}
+
+// ManifestEntry records an injected fault. Seq is the position of the
+// affected message in the published stream, starting at 1.
+type ManifestEntry struct {
+	Seq     int       `json:"seq"`
+	EventID int       `json:"event_id"`
+	Fault   Fault     `json:"fault"`
+	Detail  string    `json:"detail,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Chaos injects faults into generated events at configurable rates and
+// keeps a manifest of what it injected.
+type Chaos struct {
+	mu       sync.Mutex
+	rates    map[Fault]float64
+	rand     *rand.Rand
+	seq      int
+	manifest []ManifestEntry
+	out      io.Writer
+}
+
+// NewChaos creates a new Chaos with the given fault rates and seed. If out is
+// not nil, every manifest entry is also written to it as a line of JSON.
+func NewChaos(rates map[Fault]float64, seed int64, out io.Writer) *Chaos {
+	return &Chaos{rates: rates, rand: rand.New(rand.NewSource(seed)), out: out}
+}
+
+// Apply returns the messages to publish for the event: the event itself,
+// possibly altered or replaced by malformed bytes, and any duplicates.
+func (c *Chaos) Apply(event casino.Event) ([]Message, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var messages []Message
+	var err error
+	inject := func(f Fault) bool {
+		return c.rand.Float64() < c.rates[f]
+	}
+	record := func(f Fault, detail string) {
+		if err == nil {
+			err = c.record(ManifestEntry{Seq: c.seq + len(messages) + 1, EventID: event.ID, Fault: f, Detail: detail})
+		}
+	}
+
+	if inject(FaultOutOfOrder) {
+		shift := time.Duration(1+c.rand.Intn(3600)) * time.Second
+		event.CreatedAt = event.CreatedAt.Add(-shift)
+		record(FaultOutOfOrder, "created_at -"+shift.String())
+	}
+	if event.Currency != "" && inject(FaultUnknownCurrency) {
+		record(FaultUnknownCurrency, event.Currency+" -> "+UnknownCurrency)
+		event.Currency = UnknownCurrency
+	}
+	if event.GameID != 0 && inject(FaultUnknownGame) {
+		record(FaultUnknownGame, fmt.Sprintf("%d -> %d", event.GameID, UnknownGameID))
+		event.GameID = UnknownGameID
+	}
+	if inject(FaultUnknownPlayer) {
+		record(FaultUnknownPlayer, fmt.Sprintf("%d -> %d", event.PlayerID, UnknownPlayerID))
+		event.PlayerID = UnknownPlayerID
+	}
+	if inject(FaultMissingFields) {
+		record(FaultMissingFields, c.clearField(&event))
+	}
+
+	if inject(FaultMalformed) {
+		raw := c.malformed(event)
+		record(FaultMalformed, string(raw))
+		messages = append(messages, Message{Raw: raw})
+	} else {
+		messages = append(messages, Message{Event: event})
+		if inject(FaultDuplicate) {
+			record(FaultDuplicate, "")
+			messages = append(messages, Message{Event: event})
+		}
+	}
+
+	c.seq += len(messages)
+	return messages, err
+}
+
+// Manifest returns the faults injected so far.
+func (c *Chaos) Manifest() []ManifestEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]ManifestEntry(nil), c.manifest...)
+}
+
+// Counts returns the number of injected faults by kind.
+func (c *Chaos) Counts() map[Fault]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	counts := make(map[Fault]int)
+	for _, e := range c.manifest {
+		counts[e.Fault]++
+	}
+	return counts
+}
+
+func (c *Chaos) record(entry ManifestEntry) error {
+	entry.At = time.Now()
+	c.manifest = append(c.manifest, entry)
+	if c.out == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := c.out.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("error writing chaos manifest: %w", err)
+	}
+	return nil
+}
+
+// clearField clears one of the fields the event type requires and returns its name.
+func (c *Chaos) clearField(event *casino.Event) string {
+	fields := []string{"type", "player_id", "created_at"}
+	if event.GameID != 0 {
+		fields = append(fields, "game_id")
+	}
+	if event.Currency != "" {
+		fields = append(fields, "amount", "currency")
+	}
+	sort.Strings(fields)
+
+	field := fields[c.rand.Intn(len(fields))]
+	switch field {
+	case "type":
+		event.Type = ""
+	case "player_id":
+		event.PlayerID = 0
+	case "created_at":
+		event.CreatedAt = time.Time{}
+	case "game_id":
+		event.GameID = 0
+	case "amount":
+		event.Amount = 0
+	case "currency":
+		event.Currency = ""
+	}
+	return field
+}
+
+// malformed returns bytes that can't be decoded into an event.
+func (c *Chaos) malformed(event casino.Event) []byte {
+	valid, _ := json.Marshal(event)
+	switch c.rand.Intn(3) {
+	case 0:
+		return valid[:len(valid)/2]
+	case 1:
+		return []byte(fmt.Sprintf(`{"id": "%d", "type": 42}`, event.ID))
+	default:
+		return []byte("\x00\xffnot json")
+	}
+}
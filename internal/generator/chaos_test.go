@@ -0,0 +1,99 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+func TestChaos(t *testing.T) {
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	bet := casino.Event{ID: 1, PlayerID: 10, GameID: 100, Type: "bet", Amount: 500, Currency: "EUR", CreatedAt: at}
+
+	var out bytes.Buffer
+	chaos := NewChaos(map[Fault]float64{
+		FaultDuplicate:       1,
+		FaultOutOfOrder:      1,
+		FaultUnknownCurrency: 1,
+		FaultUnknownGame:     1,
+		FaultUnknownPlayer:   1,
+	}, 1, &out)
+
+	messages, err := chaos.Apply(bet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 || messages[0].Event != messages[1].Event {
+		t.Fatalf("Expected the event and its duplicate, got %+v", messages)
+	}
+	event := messages[0].Event
+	if !event.CreatedAt.Before(at) || event.Currency != UnknownCurrency || event.GameID != UnknownGameID || event.PlayerID != UnknownPlayerID {
+		t.Errorf("Expected all faults applied, got %+v", event)
+	}
+
+	counts := chaos.Counts()
+	for _, f := range []Fault{FaultDuplicate, FaultOutOfOrder, FaultUnknownCurrency, FaultUnknownGame, FaultUnknownPlayer} {
+		if counts[f] != 1 {
+			t.Errorf("Expected 1 %s in the manifest, got %d", f, counts[f])
+		}
+	}
+
+	// The manifest is written as JSON lines, pointing at the affected message
+	var lines []ManifestEntry
+	scanner := bufio.NewScanner(&out)
+	for scanner.Scan() {
+		var entry ManifestEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatal(err)
+		}
+		lines = append(lines, entry)
+	}
+	if len(lines) != 5 {
+		t.Fatalf("Expected 5 manifest lines, got %d", len(lines))
+	}
+	if last := lines[4]; last.Fault != FaultDuplicate || last.Seq != 2 || last.EventID != 1 {
+		t.Errorf("Expected the duplicate as message 2, got %+v", last)
+	}
+}
+
+func TestChaosMalformed(t *testing.T) {
+	chaos := NewChaos(map[Fault]float64{FaultMalformed: 1, FaultDuplicate: 1}, 1, nil)
+
+	for i := 1; i <= 10; i++ {
+		messages, err := chaos.Apply(casino.Event{ID: i, PlayerID: 10, Type: "game_start", GameID: 100, CreatedAt: time.Now()})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(messages) != 1 || messages[0].Raw == nil {
+			t.Fatalf("Expected a single raw message, got %+v", messages)
+		}
+		var event casino.Event
+		if err := json.Unmarshal(messages[0].Raw, &event); err == nil {
+			t.Errorf("Expected malformed bytes, got %s", messages[0].Raw)
+		}
+	}
+
+	if manifest := chaos.Manifest(); len(manifest) != 10 || manifest[9].Seq != 10 {
+		t.Errorf("Expected 10 malformed messages in the manifest, got %+v", manifest)
+	}
+}
+
+func TestParseFaultRates(t *testing.T) {
+	rates, err := ParseFaultRates("duplicate=0.01, malformed=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rates[FaultDuplicate] != 0.01 || rates[FaultMalformed] != 0.5 {
+		t.Errorf("Unexpected rates %+v", rates)
+	}
+
+	for _, s := range []string{"duplicate", "duplicate=2", "flood=0.1"} {
+		if _, err := ParseFaultRates(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
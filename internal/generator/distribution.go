@@ -0,0 +1,76 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+// Distribution kinds.
+const (
+	Uniform   = "uniform"
+	LogNormal = "lognormal"
+)
+
+// Distribution describes how amounts are drawn. Uniform distributions draw
+// from [Min, Max]; log-normal ones have the given Median and Sigma, the
+// standard deviation of the logarithm, which makes a few large amounts
+// among many small ones.
+type Distribution struct {
+	Kind   string
+	Min    int
+	Max    int
+	Median float64
+	Sigma  float64
+}
+
+// ParseDistribution parses "uniform:min:max" or "lognormal:median:sigma".
+func ParseDistribution(s string) (Distribution, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return Distribution{}, fmt.Errorf("invalid distribution %q, expected kind:a:b", s)
+	}
+
+	a, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return Distribution{}, fmt.Errorf("invalid distribution %q: %w", s, err)
+	}
+	b, err := strconv.ParseFloat(parts[2], 64)
+	if err != nil {
+		return Distribution{}, fmt.Errorf("invalid distribution %q: %w", s, err)
+	}
+
+	switch parts[0] {
+	case Uniform:
+		if a < 0 || b < a {
+			return Distribution{}, fmt.Errorf("invalid distribution %q, expected 0 <= min <= max", s)
+		}
+		return Distribution{Kind: Uniform, Min: int(a), Max: int(b)}, nil
+	case LogNormal:
+		if a <= 0 || b < 0 {
+			return Distribution{}, fmt.Errorf("invalid distribution %q, expected median > 0 and sigma >= 0", s)
+		}
+		return Distribution{Kind: LogNormal, Median: a, Sigma: b}, nil
+	}
+	return Distribution{}, fmt.Errorf("unknown distribution kind %q", parts[0])
+}
+
+// Sample draws an amount from the distribution using r.
+func (d Distribution) Sample(r *rand.Rand) int {
+	switch d.Kind {
+	case LogNormal:
+		return int(d.Median * math.Exp(d.Sigma*r.NormFloat64()))
+	default:
+		return d.Min + r.Intn(d.Max-d.Min+1)
+	}
+}
+
+// String formats the distribution the way ParseDistribution expects it.
+func (d Distribution) String() string {
+	if d.Kind == LogNormal {
+		return fmt.Sprintf("%s:%g:%g", LogNormal, d.Median, d.Sigma)
+	}
+	return fmt.Sprintf("%s:%d:%d", Uniform, d.Min, d.Max)
+}
@@ -3,67 +3,413 @@ package generator
 import (
 	"context"
 	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
 )
 
+// winProbability is the chance of a bet being won.
+const winProbability = 0.05
+
+// Options configures the simulated players.
+type Options struct {
+	// Players is the number of simulated players, with IDs starting at FirstPlayerID.
+	Players       int
+	FirstPlayerID int
+	// Games are the IDs of the games players choose from.
+	Games []int
+	// Currencies are the currencies players are assigned; each player keeps one.
+	Currencies []string
+	// BetSize and DepositSize are in the smallest unit of fiat currencies and
+	// scaled for currencies with smaller units, see unitScale.
+	BetSize     Distribution
+	DepositSize Distribution
+	// MaxBetsPerGame bounds the number of bets between game_start and game_stop.
+	MaxBetsPerGame int
+	// Seed makes the generated events reproducible. Zero picks a random seed,
+	// which Seed reports so the run can be repeated.
+	Seed int64
+	// Clock sets the creation time of events; nil uses the wall clock.
+	Clock Clock
+	// Profile sets the target rate of Generate; nil uses DefaultProfile.
+	Profile Profile
+	// Report, if set, is called every ReportInterval with the target and achieved rates.
+	Report         func(RateReport)
+	ReportInterval time.Duration
+	// Scenario, if set, adds scripted events to the simulated traffic.
+	Scenario *Scenario
+	// Population, if set, lists the simulated players instead of Players and FirstPlayerID.
+	Population []PlayerSpec
+	// WhaleShare is the share of players simulated as whales when Population
+	// is not set. Whales are picked WhaleWeight times as often as casual
+	// players and bet and deposit WhaleScale times as much.
+	WhaleShare  float64
+	WhaleWeight float64
+	WhaleScale  float64
+}
+
+// DefaultProfile is a constant rate of 20 events per second.
+var DefaultProfile = Constant{EPS: 20}
+
+// pacerTick is how often Generate catches up with the target rate.
+const pacerTick = 10 * time.Millisecond
+
+// DefaultOptions returns options simulating 10 players on every game and currency.
+func DefaultOptions() Options {
+	games := make([]int, 0, len(casino.Games))
+	for id := range casino.Games {
+		games = append(games, id)
+	}
+	sort.Ints(games)
+
+	return Options{
+		Players:        10,
+		FirstPlayerID:  10,
+		Games:          games,
+		Currencies:     casino.Currencies,
+		BetSize:        Distribution{Kind: Uniform, Min: 1, Max: 2000},
+		DepositSize:    Distribution{Kind: Uniform, Min: 1000, Max: 50000},
+		MaxBetsPerGame: 20,
+		ReportInterval: 10 * time.Second,
+		WhaleWeight:    10,
+		WhaleScale:     5,
+	}
+}
+
+// unitScale converts amounts drawn in cents to the smallest unit of currencies
+// worth much less than a cent, see casino.Decimals. 1 cent is roughly 20 satoshi
+// at 50000 EUR per BTC. Enrichment converts the smallest unit back to euro cents.
+var unitScale = map[string]float64{
+	"BTC": 20,
+}
+
+// Clock tells the generator what time it is.
+type Clock interface {
+	Now() time.Time
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time { return time.Now() }
+
+// StepClock is a Clock starting at a fixed time and advancing by Step on every call.
+type StepClock struct {
+	next time.Time
+	Step time.Duration
+}
+
+// NewStepClock creates a new StepClock.
+func NewStepClock(start time.Time, step time.Duration) *StepClock {
+	return &StepClock{next: start, Step: step}
+}
+
+// Now returns the current time of the clock and advances it.
+func (c *StepClock) Now() time.Time {
+	now := c.next
+	c.next = c.next.Add(c.Step)
+	return now
+}
+
+// Generator simulates players as state machines and emits their events.
+type Generator struct {
+	options    Options
+	seed       int64
+	rand       *rand.Rand
+	clock      Clock
+	players    []*player
+	population []PlayerSpec
+	weights    []float64
+	id         int
+}
+
+// New creates a new Generator.
+func New(options Options) *Generator {
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	clock := options.Clock
+	if clock == nil {
+		clock = wallClock{}
+	}
+
+	g := &Generator{
+		options: options,
+		seed:    seed,
+		rand:    rand.New(rand.NewSource(seed)),
+		clock:   clock,
+	}
+
+	population := options.Population
+	if population == nil {
+		population = g.simulatedPopulation()
+	}
+
+	var total float64
+	weighted := false
+	for _, spec := range population {
+		currency := spec.Currency
+		if currency == "" {
+			currency = options.Currencies[g.rand.Intn(len(options.Currencies))]
+		}
+		scale := 1.0
+		if spec.Whale {
+			scale = options.WhaleScale
+		}
+		g.players = append(g.players, &player{id: spec.ID, currency: currency, scale: scale})
+		spec.Currency = currency
+		g.population = append(g.population, spec)
+
+		weight := g.weight(spec)
+		weighted = weighted || weight != 1
+		total += weight
+		g.weights = append(g.weights, total)
+	}
+	if !weighted {
+		// Pick players uniformly as before whales existed, so seeds keep
+		// reproducing the same events.
+		g.weights = nil
+	}
+	return g
+}
+
+// Population returns the simulated players with their assigned currencies.
+func (g *Generator) Population() []PlayerSpec {
+	return append([]PlayerSpec(nil), g.population...)
+}
+
+// simulatedPopulation returns Players players with consecutive IDs, a
+// WhaleShare of which are whales. Without whales no random numbers are drawn.
+func (g *Generator) simulatedPopulation() []PlayerSpec {
+	specs := make([]PlayerSpec, g.options.Players)
+	for i := range specs {
+		specs[i] = PlayerSpec{ID: g.options.FirstPlayerID + i}
+		if g.options.WhaleShare > 0 {
+			specs[i].Whale = g.rand.Float64() < g.options.WhaleShare
+		}
+	}
+	return specs
+}
+
+// weight returns how often the player is picked relative to a casual player.
+func (g *Generator) weight(spec PlayerSpec) float64 {
+	if spec.Whale {
+		return g.options.WhaleWeight
+	}
+	return 1
+}
+
+// Seed returns the seed of the generator, to reproduce its events with Options.Seed.
+func (g *Generator) Seed() int64 {
+	return g.seed
+}
+
+// Generate emits events of the default simulated players until ctx is done.
 func Generate(ctx context.Context) <-chan casino.Event {
+	return New(DefaultOptions()).Generate(ctx)
+}
+
+// Generate emits events at the rate of the load profile until ctx is done.
+// If the receiver can't keep up, the generator falls behind by at most a
+// second of events and reports the achieved rate below the target.
+// Scripted events of the scenario are emitted when their offset is reached;
+// a scenario without background traffic ends generation after its last event.
+func (g *Generator) Generate(ctx context.Context) <-chan casino.Event {
 	eventCh := make(chan casino.Event)
-	var id int
+
+	profile := g.options.Profile
+	if profile == nil {
+		profile = DefaultProfile
+	}
+
+	var timeline []TimedEvent
+	background := true
+	if g.options.Scenario != nil {
+		timeline = g.options.Scenario.Timeline()
+		background = g.options.Scenario.Background
+	}
 
 	go func() {
 		defer close(eventCh)
 
-		for {
-			id++
+		ticker := time.NewTicker(pacerTick)
+		defer ticker.Stop()
+
+		p := &pacer{profile: profile}
+		start := time.Now()
+		last := start
+		report := RateReport{}
+		reportStart := start
 
+		send := func(event casino.Event) bool {
 			select {
 			case <-ctx.Done():
-				return
-			default:
-				eventCh <- generate(id)
+				return false
+			case eventCh <- event:
+				report.Events++
+				return true
 			}
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-ticker.C:
+				elapsed := now.Sub(start)
+
+				for len(timeline) > 0 && timeline[0].Offset <= elapsed {
+					if !send(g.script(timeline[0].Event)) {
+						return
+					}
+					timeline = timeline[1:]
+				}
+				if !background {
+					if len(timeline) == 0 {
+						return
+					}
+					continue
+				}
+
+				owed := p.advance(elapsed, now.Sub(last))
+				report.Target += profile.Rate(elapsed) * now.Sub(last).Seconds()
+				last = now
 
-			time.Sleep(time.Duration(rand.Intn(100)) * time.Millisecond)
+				for i := 0; i < owed; i++ {
+					if !send(g.Next()) {
+						return
+					}
+					p.emitted++
+				}
+
+				if g.options.Report != nil && now.Sub(reportStart) >= g.options.ReportInterval {
+					seconds := now.Sub(reportStart).Seconds()
+					report.Elapsed = elapsed
+					report.Target /= seconds
+					report.Achieved = float64(report.Events) / seconds
+					g.options.Report(report)
+					report = RateReport{}
+					reportStart = now
+				}
+			}
 		}
 	}()
 
 	return eventCh
 }
 
-func generate(id int) casino.Event {
-	amount, currency := randomAmountCurrency()
+// Next advances a random player by one step and returns the resulting event.
+func (g *Generator) Next() casino.Event {
+	g.id++
+	return g.pick().next(g.rand, g.id, g.options, g.clock.Now())
+}
 
-	return casino.Event{
-		ID:        id,
-		PlayerID:  10 + rand.Intn(10),
-		GameID:    100 + rand.Intn(10),
-		Type:      randomType(),
-		Amount:    amount,
-		Currency:  currency,
-		HasWon:    randomHasWon(),
-		CreatedAt: time.Now(),
+// pick returns a random player, weighted if the population has whales.
+func (g *Generator) pick() *player {
+	if g.weights == nil {
+		return g.players[g.rand.Intn(len(g.players))]
 	}
+	i := sort.SearchFloat64s(g.weights, g.rand.Float64()*g.weights[len(g.weights)-1])
+	return g.players[i]
 }
 
-func randomType() string {
-	return casino.EventTypes[rand.Intn(len(casino.EventTypes))]
+// script assigns the next ID and the current time to a scripted event.
+func (g *Generator) script(event casino.Event) casino.Event {
+	g.id++
+	event.ID = g.id
+	event.CreatedAt = g.clock.Now()
+	return event
 }
 
-func randomAmountCurrency() (amount int, currency string) {
-	currency = casino.Currencies[rand.Intn(len(casino.Currencies))]
+// state is the state of a simulated player.
+type state int
 
-	switch currency {
-	case "BTC":
-		amount = rand.Intn(1e5)
-	default:
-		amount = rand.Intn(2000)
+const (
+	// stateLobby is a player outside of a game.
+	stateLobby state = iota
+	// statePlaying is a player between game_start and game_stop.
+	statePlaying
+)
+
+// player is a simulated player. Players deposit when their balance can't
+// cover a bet, then start a game, bet a number of times and stop it again.
+type player struct {
+	id       int
+	currency string
+	scale    float64
+	state    state
+	balance  int
+	gameID   int
+	betsLeft int
+	nextBet  int
+}
+
+// next moves the player to its next state and returns the event of the transition.
+func (p *player) next(r *rand.Rand, id int, o Options, now time.Time) casino.Event {
+	event := casino.Event{ID: id, PlayerID: p.id, CreatedAt: now}
+
+	if p.nextBet == 0 {
+		p.nextBet = p.amount(r, o.BetSize)
 	}
 
-	return
+	switch p.state {
+	case stateLobby:
+		if p.balance < p.nextBet {
+			event.Type = "deposit"
+			event.Amount = p.amount(r, o.DepositSize)
+			event.Currency = p.currency
+			p.balance += event.Amount
+			return event
+		}
+
+		p.state = statePlaying
+		p.gameID = o.Games[r.Intn(len(o.Games))]
+		p.betsLeft = 1 + r.Intn(o.MaxBetsPerGame)
+		event.Type = "game_start"
+		event.GameID = p.gameID
+
+	case statePlaying:
+		event.GameID = p.gameID
+		if p.betsLeft == 0 || p.balance < p.nextBet {
+			p.state = stateLobby
+			event.Type = "game_stop"
+			return event
+		}
+
+		event.Type = "bet"
+		event.Amount = p.nextBet
+		event.Currency = p.currency
+		p.balance -= event.Amount
+		p.betsLeft--
+		p.nextBet = 0
+
+		if r.Float64() < winProbability {
+			event.HasWon = true
+			event.WinAmount = randomWinAmount(r, event.Amount, casino.Games[p.gameID].RTP)
+			p.balance += event.WinAmount
+		}
+	}
+
+	return event
+}
+
+// amount draws an amount from the distribution in the unit of the player currency.
+func (p *player) amount(r *rand.Rand, d Distribution) int {
+	amount := int(float64(d.Sample(r)) * p.scale)
+	if scale, ok := unitScale[p.currency]; ok {
+		amount = int(float64(amount) * scale)
+	}
+	if amount < 1 {
+		amount = 1
+	}
+	return amount
 }
 
-func randomHasWon() bool {
-	return rand.Intn(100) < 5
+// randomWinAmount returns the payout of a winning bet. Multipliers are
+// exponentially distributed with a mean chosen so that, given the win
+// probability, the game pays back its RTP on average.
+func randomWinAmount(r *rand.Rand, amount int, rtp float64) int {
+	multiplier := r.ExpFloat64() * rtp / winProbability
+	return int(float64(amount) * multiplier)
 }
@@ -0,0 +1,138 @@
+package generator
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGeneratorSessions(t *testing.T) {
+	options := DefaultOptions()
+	options.Players = 5
+	options.Games = []int{100, 101}
+	options.Currencies = []string{"EUR", "BTC"}
+	g := New(options)
+
+	playing := make(map[int]int)
+	currencies := make(map[int]string)
+	var lastID int
+
+	for i := 0; i < 5000; i++ {
+		event := g.Next()
+		if event.ID != lastID+1 {
+			t.Fatalf("Expected ID %d, got %d", lastID+1, event.ID)
+		}
+		lastID = event.ID
+
+		if event.PlayerID < 10 || event.PlayerID >= 15 {
+			t.Fatalf("Unexpected player %d", event.PlayerID)
+		}
+
+		switch event.Type {
+		case "deposit":
+			if event.GameID != 0 || event.Amount <= 0 || event.HasWon {
+				t.Fatalf("Invalid deposit %+v", event)
+			}
+			if _, ok := playing[event.PlayerID]; ok {
+				t.Fatalf("Deposit during a game %+v", event)
+			}
+		case "game_start":
+			if _, ok := playing[event.PlayerID]; ok {
+				t.Fatalf("game_start during a game %+v", event)
+			}
+			if event.GameID != 100 && event.GameID != 101 {
+				t.Fatalf("Unexpected game %+v", event)
+			}
+			if event.Amount != 0 || event.Currency != "" {
+				t.Fatalf("game_start with amount %+v", event)
+			}
+			playing[event.PlayerID] = event.GameID
+		case "bet":
+			if playing[event.PlayerID] != event.GameID {
+				t.Fatalf("Bet outside of its game %+v", event)
+			}
+			if event.Amount <= 0 || (!event.HasWon && event.WinAmount != 0) {
+				t.Fatalf("Invalid bet %+v", event)
+			}
+		case "game_stop":
+			if playing[event.PlayerID] != event.GameID {
+				t.Fatalf("game_stop outside of its game %+v", event)
+			}
+			delete(playing, event.PlayerID)
+		default:
+			t.Fatalf("Unexpected type %q", event.Type)
+		}
+
+		if event.Currency != "" {
+			if c, ok := currencies[event.PlayerID]; ok && c != event.Currency {
+				t.Fatalf("Player %d switched currency from %s to %s", event.PlayerID, c, event.Currency)
+			}
+			currencies[event.PlayerID] = event.Currency
+		}
+	}
+}
+
+func TestParseDistribution(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{"uniform:1:2000", true},
+		{"lognormal:500:1.2", true},
+		{"uniform:10:1", false},
+		{"lognormal:0:1", false},
+		{"normal:1:2", false},
+		{"uniform:1", false},
+	}
+
+	for _, tt := range tests {
+		d, err := ParseDistribution(tt.input)
+		if (err == nil) != tt.valid {
+			t.Errorf("ParseDistribution(%q) error = %v, want valid %v", tt.input, err, tt.valid)
+			continue
+		}
+		if tt.valid && d.String() != tt.input {
+			t.Errorf("Expected %q, got %q", tt.input, d.String())
+		}
+	}
+
+	d := Distribution{Kind: Uniform, Min: 5, Max: 10}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 100; i++ {
+		if v := d.Sample(r); v < 5 || v > 10 {
+			t.Fatalf("Sample %d outside of [5, 10]", v)
+		}
+	}
+}
+
+func TestGeneratorPopulation(t *testing.T) {
+	options := DefaultOptions()
+	options.Seed = 1
+	options.Population = []PlayerSpec{
+		{ID: 10, Currency: "EUR", Whale: true},
+		{ID: 11, Currency: "BTC"},
+		{ID: 12},
+	}
+	g := New(options)
+
+	counts := make(map[int]int)
+	for i := 0; i < 12000; i++ {
+		event := g.Next()
+		counts[event.PlayerID]++
+		if event.PlayerID == 11 && event.Currency != "" && event.Currency != "BTC" {
+			t.Fatalf("Expected player 11 to use its preferred currency, got %+v", event)
+		}
+	}
+
+	// The whale is picked 10 times as often as each casual player
+	if counts[10] < 8*counts[11] || counts[10] > 12*counts[11] {
+		t.Errorf("Expected the whale to be about 10 times as active, got %v", counts)
+	}
+	if len(counts) != 3 {
+		t.Errorf("Expected only the given players, got %v", counts)
+	}
+
+	population := g.Population()
+	if population[2].Currency == "" {
+		t.Errorf("Expected a currency assigned to player 12, got %+v", population[2])
+	}
+}
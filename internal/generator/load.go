@@ -0,0 +1,159 @@
+package generator
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Profile gives the target rate in events per second at a time since the start of generation.
+type Profile interface {
+	Rate(elapsed time.Duration) float64
+}
+
+// Constant generates events at a fixed rate.
+type Constant struct {
+	EPS float64
+}
+
+// Rate returns the constant rate.
+func (p Constant) Rate(time.Duration) float64 {
+	return p.EPS
+}
+
+// Ramp changes the rate linearly from From to To over Duration, then holds To.
+type Ramp struct {
+	From     float64
+	To       float64
+	Duration time.Duration
+}
+
+// Rate returns the rate on the ramp.
+func (p Ramp) Rate(elapsed time.Duration) float64 {
+	if elapsed >= p.Duration {
+		return p.To
+	}
+	return p.From + (p.To-p.From)*float64(elapsed)/float64(p.Duration)
+}
+
+// Burst generates events at Base, switching to Peak for Length at the start of every Every.
+type Burst struct {
+	Base   float64
+	Peak   float64
+	Every  time.Duration
+	Length time.Duration
+}
+
+// Rate returns the peak rate during a burst and the base rate otherwise.
+func (p Burst) Rate(elapsed time.Duration) float64 {
+	if elapsed%p.Every < p.Length {
+		return p.Peak
+	}
+	return p.Base
+}
+
+// Diurnal follows a daily curve between Min and Max: the rate starts at Min,
+// peaks at Max halfway through Period and falls back to Min.
+type Diurnal struct {
+	Min    float64
+	Max    float64
+	Period time.Duration
+}
+
+// Rate returns the rate on the curve.
+func (p Diurnal) Rate(elapsed time.Duration) float64 {
+	phase := 2 * math.Pi * float64(elapsed%p.Period) / float64(p.Period)
+	return p.Min + (p.Max-p.Min)*(1-math.Cos(phase))/2
+}
+
+// ParseProfile parses a load profile:
+//
+//	constant:EPS
+//	ramp:FROM:TO:DURATION
+//	burst:BASE:PEAK:EVERY:LENGTH
+//	diurnal:MIN:MAX:PERIOD
+func ParseProfile(s string) (Profile, error) {
+	parts := strings.Split(s, ":")
+	kind, args := parts[0], parts[1:]
+
+	expect := map[string]int{"constant": 1, "ramp": 3, "burst": 4, "diurnal": 3}
+	n, ok := expect[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown load profile %q", kind)
+	}
+	if len(args) != n {
+		return nil, fmt.Errorf("invalid load profile %q, expected %d arguments", s, n)
+	}
+
+	var err error
+	rate := func(i int) float64 {
+		v, parseErr := strconv.ParseFloat(args[i], 64)
+		if parseErr == nil && (math.IsNaN(v) || math.IsInf(v, 0) || v < 0) {
+			parseErr = fmt.Errorf("rate must be a finite number that is not negative")
+		}
+		if parseErr != nil && err == nil {
+			err = fmt.Errorf("invalid rate %q in load profile %q: %w", args[i], s, parseErr)
+		}
+		return v
+	}
+	duration := func(i int) time.Duration {
+		d, parseErr := time.ParseDuration(args[i])
+		if parseErr == nil && d <= 0 {
+			parseErr = fmt.Errorf("duration must be positive")
+		}
+		if parseErr != nil && err == nil {
+			err = fmt.Errorf("invalid duration %q in load profile %q: %w", args[i], s, parseErr)
+		}
+		return d
+	}
+
+	var profile Profile
+	switch kind {
+	case "constant":
+		profile = Constant{EPS: rate(0)}
+	case "ramp":
+		profile = Ramp{From: rate(0), To: rate(1), Duration: duration(2)}
+	case "burst":
+		profile = Burst{Base: rate(0), Peak: rate(1), Every: duration(2), Length: duration(3)}
+	case "diurnal":
+		profile = Diurnal{Min: rate(0), Max: rate(1), Period: duration(2)}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// RateReport compares the target and achieved rates, in events per second,
+// over a reporting interval ending Elapsed after the start of generation.
+type RateReport struct {
+	Elapsed  time.Duration
+	Events   int
+	Target   float64
+	Achieved float64
+}
+
+// pacer keeps track of how many events should have been generated according
+// to a profile. It integrates the rate over small ticks, so rates far above
+// the timer resolution are reached by emitting several events per tick.
+type pacer struct {
+	profile Profile
+	due     float64
+	emitted int
+}
+
+// maxDebt bounds how far behind the generator may fall, in seconds of the
+// current rate, before the missed events are dropped instead of caught up.
+const maxDebt = 1.0
+
+// advance adds the events due over dt at elapsed and returns how many are owed.
+func (p *pacer) advance(elapsed, dt time.Duration) int {
+	rate := p.profile.Rate(elapsed)
+	p.due += rate * dt.Seconds()
+	if owed := p.due - float64(p.emitted); owed > math.Max(rate*maxDebt, 1) {
+		p.due = float64(p.emitted) + math.Max(rate*maxDebt, 1)
+	}
+	return int(p.due) - p.emitted
+}
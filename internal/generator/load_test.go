@@ -0,0 +1,92 @@
+package generator
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestProfiles(t *testing.T) {
+	tests := []struct {
+		spec     string
+		elapsed  time.Duration
+		expected float64
+	}{
+		{"constant:100", time.Hour, 100},
+		{"ramp:0:1000:10s", 0, 0},
+		{"ramp:0:1000:10s", 5 * time.Second, 500},
+		{"ramp:0:1000:10s", time.Minute, 1000},
+		{"burst:10:5000:1m:10s", 65 * time.Second, 5000},
+		{"burst:10:5000:1m:10s", 30 * time.Second, 10},
+		{"diurnal:10:110:24h", 0, 10},
+		{"diurnal:10:110:24h", 12 * time.Hour, 110},
+		{"diurnal:10:110:24h", 6 * time.Hour, 60},
+	}
+
+	for _, tt := range tests {
+		profile, err := ParseProfile(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseProfile(%q): %v", tt.spec, err)
+		}
+		if got := profile.Rate(tt.elapsed); got < tt.expected-1e-6 || got > tt.expected+1e-6 {
+			t.Errorf("%s at %s: expected %f, got %f", tt.spec, tt.elapsed, tt.expected, got)
+		}
+	}
+
+	for _, spec := range []string{"constant", "constant:-1", "constant:NaN", "constant:Inf", "ramp:1:+Inf:1m", "diurnal:-Inf:1:1h", "ramp:1:2:0s", "burst:1:2:1m", "square:1"} {
+		if _, err := ParseProfile(spec); err == nil {
+			t.Errorf("Expected error for %q", spec)
+		}
+	}
+}
+
+func TestPacer(t *testing.T) {
+	p := &pacer{profile: Constant{EPS: 250}}
+
+	// 250 events/s over 10ms ticks is 2.5 events per tick
+	if owed := p.advance(0, 10*time.Millisecond); owed != 2 {
+		t.Errorf("Expected 2 events owed, got %d", owed)
+	}
+	p.emitted += 2
+	if owed := p.advance(10*time.Millisecond, 10*time.Millisecond); owed != 3 {
+		t.Errorf("Expected 3 events owed, got %d", owed)
+	}
+
+	// Missed events are capped at a second of the current rate
+	if owed := p.advance(time.Minute, time.Minute); owed != 250 {
+		t.Errorf("Expected owed events capped at 250, got %d", owed)
+	}
+}
+
+func TestGenerateRate(t *testing.T) {
+	reports := make(chan RateReport, 10)
+	options := DefaultOptions()
+	options.Profile = Constant{EPS: 20000}
+	options.ReportInterval = 100 * time.Millisecond
+	options.Report = func(r RateReport) { reports <- r }
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	var count int
+	for range New(options).Generate(ctx) {
+		count++
+	}
+
+	// 6000 events are due; allow for slow test machines
+	if count < 3000 {
+		t.Errorf("Expected about 6000 events, got %d", count)
+	}
+
+	select {
+	case r := <-reports:
+		if r.Target < 19000 || r.Target > 21000 {
+			t.Errorf("Expected a target of 20000 events/s, got %f", r.Target)
+		}
+		if r.Achieved <= 0 {
+			t.Errorf("Expected an achieved rate, got %f", r.Achieved)
+		}
+	default:
+		t.Error("Expected a rate report")
+	}
+}
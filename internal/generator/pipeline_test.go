@@ -0,0 +1,101 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/enrichment"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/materialize"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// testRates are fixed exchange rates, so enrichment doesn't depend on the API.
+var testRates = map[string]float64{
+	"EUR": 1.0,
+	"USD": 1.1,
+	"GBP": 0.85,
+	"NZD": 1.8,
+	"BTC": 0.00002,
+}
+
+func TestSeedReproducible(t *testing.T) {
+	generate := func() []casino.Event {
+		options := DefaultOptions()
+		options.Seed = 42
+		options.Clock = NewStepClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Second)
+		g := New(options)
+
+		var events []casino.Event
+		for i := 0; i < 1000; i++ {
+			events = append(events, g.Next())
+		}
+		return events
+	}
+
+	first, second := generate(), generate()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("Event %d differs between runs: %+v and %+v", i, first[i], second[i])
+		}
+	}
+}
+
+// TestPipelineGolden runs seeded events through enrichment and the materializer
+// and compares the result with testdata/pipeline.golden.json.
+// Run with -update to regenerate the golden file after intended changes.
+func TestPipelineGolden(t *testing.T) {
+	options := DefaultOptions()
+	options.Players = 3
+	options.Seed = 1
+	options.Clock = NewStepClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), 1500*time.Millisecond)
+	g := New(options)
+	materializer := materialize.NewMaterialize()
+
+	var events []casino.Event
+	for i := 0; i < 50; i++ {
+		event := g.Next()
+		if event.Currency != "" {
+			event.AmountEUR = enrichment.ConvertToEURWithRates(event.Amount, event.Currency, testRates)
+		}
+		if event.WinAmount > 0 {
+			event.WinAmountEUR = enrichment.ConvertToEURWithRates(event.WinAmount, event.Currency, testRates)
+		}
+		event.Description = enrichment.GenerateDescription(event)
+
+		materializer.UpdateStats(event)
+		events = append(events, event)
+	}
+
+	got, err := json.MarshalIndent(struct {
+		Events []casino.Event    `json:"events"`
+		Stats  materialize.Stats `json:"stats"`
+	}{events, materializer.QueryStats(materialize.Query{})}, "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "pipeline.golden.json")
+	if *update {
+		if err := os.MkdirAll("testdata", 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(golden, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Pipeline output differs from %s, run with -update if the change is intended:\n%s", golden, got)
+	}
+}
@@ -0,0 +1,95 @@
+package generator
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// PlayerSpec describes a simulated player.
+type PlayerSpec struct {
+	ID int
+	// Currency is the preferred currency of the player; empty picks a random one.
+	Currency string
+	// Whale players are picked more often and bet more, see Options.
+	Whale bool
+}
+
+// PopulationRepository loads and seeds the simulated players in Postgres.
+type PopulationRepository struct {
+	db *sql.DB
+}
+
+// NewPopulationRepository creates a new PopulationRepository.
+func NewPopulationRepository(databaseURL string) (*PopulationRepository, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &PopulationRepository{db: db}, nil
+}
+
+// Close closes the database connection.
+func (r *PopulationRepository) Close() error {
+	return r.db.Close()
+}
+
+// LoadPopulation returns the players in the players table.
+func (r *PopulationRepository) LoadPopulation(ctx context.Context) ([]PlayerSpec, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, COALESCE(preferred_currency, ''), segment = 'whale' FROM players ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("error loading players: %w", err)
+	}
+	defer rows.Close()
+
+	var specs []PlayerSpec
+	for rows.Next() {
+		var spec PlayerSpec
+		if err := rows.Scan(&spec.ID, &spec.Currency, &spec.Whale); err != nil {
+			return nil, fmt.Errorf("error loading players: %w", err)
+		}
+		if spec.Currency != "" && !casino.IsCurrency(spec.Currency) {
+			return nil, fmt.Errorf("player %d has unknown preferred currency %q", spec.ID, spec.Currency)
+		}
+		specs = append(specs, spec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error loading players: %w", err)
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no players found")
+	}
+	return specs, nil
+}
+
+// SeedPlayers inserts the players that don't exist yet, so every generated
+// event refers to a known player. Existing players are left unchanged.
+func (r *PopulationRepository) SeedPlayers(ctx context.Context, specs []PlayerSpec) (int, error) {
+	var seeded int
+	for _, spec := range specs {
+		segment := "casual"
+		if spec.Whale {
+			segment = "whale"
+		}
+		var currency interface{}
+		if spec.Currency != "" {
+			currency = spec.Currency
+		}
+
+		res, err := r.db.ExecContext(ctx, `INSERT INTO players (id, email, last_signed_in_at, preferred_currency, segment)
+			VALUES ($1, $2, now(), $3, $4) ON CONFLICT (id) DO NOTHING`,
+			spec.ID, fmt.Sprintf("player%d@example.com", spec.ID), currency, segment)
+		if err != nil {
+			return seeded, fmt.Errorf("error seeding player %d: %w", spec.ID, err)
+		}
+		n, _ := res.RowsAffected()
+		seeded += int(n)
+	}
+	return seeded, nil
+}
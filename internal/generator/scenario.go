@@ -0,0 +1,187 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario scripts sequences of events per player, e.g. to reproduce fraud
+// or responsible gambling flows.
+type Scenario struct {
+	// Background mixes the scripted events with simulated traffic. Without it,
+	// generation stops after the last scripted event.
+	Background bool       `json:"background" yaml:"background"`
+	Sequences  []Sequence `json:"sequences" yaml:"sequences"`
+}
+
+// Sequence is a scripted series of events of one player.
+type Sequence struct {
+	Name     string `json:"name" yaml:"name"`
+	PlayerID int    `json:"player_id" yaml:"player_id"`
+	// Start is the offset of the first event from the start of generation.
+	Start Duration `json:"start" yaml:"start"`
+	// Repeat plays the sequence this many times back to back, at least once.
+	Repeat int             `json:"repeat" yaml:"repeat"`
+	Events []ScriptedEvent `json:"events" yaml:"events"`
+}
+
+// ScriptedEvent is an event of a sequence, After the previous one.
+type ScriptedEvent struct {
+	After     Duration `json:"after" yaml:"after"`
+	Type      string   `json:"type" yaml:"type"`
+	GameID    int      `json:"game_id" yaml:"game_id"`
+	Amount    int      `json:"amount" yaml:"amount"`
+	Currency  string   `json:"currency" yaml:"currency"`
+	HasWon    bool     `json:"has_won" yaml:"has_won"`
+	WinAmount int      `json:"win_amount" yaml:"win_amount"`
+}
+
+// Duration is a time.Duration written as a string like "1m30s".
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string like \"10s\": %w", err)
+	}
+	return d.parse(s)
+}
+
+// UnmarshalYAML parses a duration string.
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	return d.parse(value.Value)
+}
+
+func (d *Duration) parse(s string) error {
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// TimedEvent is a scripted event with its offset from the start of generation.
+type TimedEvent struct {
+	Offset   time.Duration
+	Sequence string
+	Event    casino.Event
+}
+
+// LoadScenario reads a scenario from a YAML (.yaml, .yml) or JSON file.
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading scenario: %w", err)
+	}
+
+	var scenario Scenario
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &scenario)
+	case ".json":
+		err = json.Unmarshal(data, &scenario)
+	default:
+		return nil, fmt.Errorf("unknown scenario format %q, expected .yaml, .yml or .json", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error decoding scenario %s: %w", path, err)
+	}
+
+	if err := scenario.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", path, err)
+	}
+	return &scenario, nil
+}
+
+// Validate checks that every scripted event is complete for its type and
+// refers to known games and currencies. Scripted events bypass the simulated
+// players, so nothing else catches a typo before enrichment.
+func (s *Scenario) Validate() error {
+	for i, seq := range s.Sequences {
+		name := seq.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if seq.PlayerID <= 0 {
+			return fmt.Errorf("sequence %s: player_id is required", name)
+		}
+		if seq.Repeat < 0 || seq.Start < 0 {
+			return fmt.Errorf("sequence %s: start and repeat must not be negative", name)
+		}
+		if len(seq.Events) == 0 {
+			return fmt.Errorf("sequence %s: no events", name)
+		}
+
+		for j, e := range seq.Events {
+			if e.After < 0 {
+				return fmt.Errorf("sequence %s, event %d: after must not be negative", name, j+1)
+			}
+			switch e.Type {
+			case "deposit":
+				if e.Amount <= 0 || e.Currency == "" {
+					return fmt.Errorf("sequence %s, event %d: deposit requires amount and currency", name, j+1)
+				}
+			case "bet":
+				if e.GameID == 0 || e.Amount <= 0 || e.Currency == "" {
+					return fmt.Errorf("sequence %s, event %d: bet requires game_id, amount and currency", name, j+1)
+				}
+			case "game_start", "game_stop":
+				if e.GameID == 0 {
+					return fmt.Errorf("sequence %s, event %d: %s requires game_id", name, j+1, e.Type)
+				}
+			default:
+				return fmt.Errorf("sequence %s, event %d: unknown type %q", name, j+1, e.Type)
+			}
+			if _, ok := casino.Games[e.GameID]; e.GameID != 0 && !ok {
+				return fmt.Errorf("sequence %s, event %d: unknown game_id %d", name, j+1, e.GameID)
+			}
+			if e.Currency != "" && !casino.IsCurrency(e.Currency) {
+				return fmt.Errorf("sequence %s, event %d: unknown currency %q", name, j+1, e.Currency)
+			}
+		}
+	}
+	return nil
+}
+
+// Timeline returns the scripted events of all sequences ordered by offset.
+// Events at the same offset keep the order of the file.
+func (s *Scenario) Timeline() []TimedEvent {
+	var timeline []TimedEvent
+	for _, seq := range s.Sequences {
+		offset := time.Duration(seq.Start)
+		repeat := seq.Repeat
+		if repeat < 1 {
+			repeat = 1
+		}
+
+		for r := 0; r < repeat; r++ {
+			for _, e := range seq.Events {
+				offset += time.Duration(e.After)
+				event := casino.Event{
+					PlayerID: seq.PlayerID,
+					GameID:   e.GameID,
+					Type:     e.Type,
+					Amount:   e.Amount,
+					Currency: e.Currency,
+				}
+				if e.Type == "bet" && e.HasWon {
+					event.HasWon = true
+					event.WinAmount = e.WinAmount
+				}
+				timeline = append(timeline, TimedEvent{Offset: offset, Sequence: seq.Name, Event: event})
+			}
+		}
+	}
+
+	sort.SliceStable(timeline, func(i, j int) bool { return timeline[i].Offset < timeline[j].Offset })
+	return timeline
+}
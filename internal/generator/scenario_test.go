@@ -0,0 +1,100 @@
+package generator
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadScenario(t *testing.T) {
+	files, err := filepath.Glob("../../scenarios/*")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("Expected example scenarios, got %v, %v", files, err)
+	}
+	for _, file := range files {
+		if _, err := LoadScenario(file); err != nil {
+			t.Errorf("LoadScenario(%s): %v", file, err)
+		}
+	}
+
+	scenario, err := LoadScenario("../../scenarios/lucky-streak.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	timeline := scenario.Timeline()
+	expected := []struct {
+		offset time.Duration
+		typ    string
+	}{
+		{0, "deposit"},
+		{time.Second, "game_start"},
+		{2500 * time.Millisecond, "bet"},
+		{3 * time.Second, "bet"},
+		{3500 * time.Millisecond, "bet"},
+		{5 * time.Second, "game_stop"},
+	}
+	if len(timeline) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(timeline))
+	}
+	for i, e := range expected {
+		if timeline[i].Offset != e.offset || timeline[i].Event.Type != e.typ {
+			t.Errorf("Event %d: expected %s at %s, got %s at %s", i, e.typ, e.offset, timeline[i].Event.Type, timeline[i].Offset)
+		}
+	}
+	if !timeline[2].Event.HasWon || timeline[2].Event.WinAmount != 5000 {
+		t.Errorf("Expected a winning bet, got %+v", timeline[2].Event)
+	}
+}
+
+func TestLoadScenarioInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "invalid.yaml")
+	content := "sequences:\n  - player_id: 10\n    events:\n      - {type: bet, amount: 100}\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadScenario(path); err == nil {
+		t.Error("Expected an error for a bet without game_id and currency")
+	}
+}
+
+func TestScenarioValidateUnknownGameAndCurrency(t *testing.T) {
+	for _, e := range []ScriptedEvent{
+		{Type: "bet", GameID: 999, Amount: 100, Currency: "EUR"},
+		{Type: "deposit", Amount: 100, Currency: "EURO"},
+	} {
+		scenario := Scenario{Sequences: []Sequence{{PlayerID: 10, Events: []ScriptedEvent{e}}}}
+		if err := scenario.Validate(); err == nil {
+			t.Errorf("Expected an error for %+v", e)
+		}
+	}
+}
+
+func TestGenerateScenario(t *testing.T) {
+	options := DefaultOptions()
+	options.Scenario = &Scenario{Sequences: []Sequence{{
+		Name:     "deposits",
+		PlayerID: 42,
+		Repeat:   3,
+		Events:   []ScriptedEvent{{Type: "deposit", Amount: 100, Currency: "EUR", After: Duration(20 * time.Millisecond)}},
+	}}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var ids []int
+	for event := range New(options).Generate(ctx) {
+		if event.PlayerID != 42 || event.Type != "deposit" {
+			t.Errorf("Expected only scripted deposits without background traffic, got %+v", event)
+		}
+		ids = append(ids, event.ID)
+	}
+
+	if ctx.Err() != nil {
+		t.Fatal("Expected generation to end after the last scripted event")
+	}
+	if len(ids) != 3 || ids[0] != 1 || ids[2] != 3 {
+		t.Errorf("Expected events 1 to 3, got %v", ids)
+	}
+}
@@ -0,0 +1,165 @@
+// Package health This reports the status of the dependencies of a service over HTTP.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Check reports the status of a dependency. It returns a short detail, e.g.
+// the age of cached data, and an error if the dependency is degraded.
+type Check func(ctx context.Context) (string, error)
+
+// Result is the status of one dependency.
+type Result struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Report is the status of a service and its dependencies.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Statuses of a Result and Report.
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+)
+
+// Checker runs the registered checks. Liveness checks, e.g. whether a
+// worker is still running, are reported by /healthz and /readyz; readiness
+// checks, e.g. whether a database answers, only by /readyz.
+type Checker struct {
+	timeout time.Duration
+
+	mu    sync.Mutex
+	live  map[string]Check
+	ready map[string]Check
+}
+
+// NewChecker creates a new Checker giving each check the timeout to complete.
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{
+		timeout: timeout,
+		live:    make(map[string]Check),
+		ready:   make(map[string]Check),
+	}
+}
+
+// Live registers a liveness check. It panics if a check of the same name is
+// already registered, since one would hide the other in the report.
+func (c *Checker) Live(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.register(name)
+	c.live[name] = check
+}
+
+// Ready registers a readiness check. It panics like Live on duplicate names.
+func (c *Checker) Ready(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.register(name)
+	c.ready[name] = check
+}
+
+// register panics if the name is taken. Callers hold mu.
+func (c *Checker) register(name string) {
+	_, live := c.live[name]
+	_, ready := c.ready[name]
+	if live || ready {
+		panic("health: duplicate check " + name)
+	}
+}
+
+// Run runs the liveness checks, and the readiness checks if ready is set,
+// concurrently and reports their results.
+func (c *Checker) Run(ctx context.Context, ready bool) Report {
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.live)+len(c.ready))
+	for name, check := range c.live {
+		checks[name] = check
+	}
+	if ready {
+		for name, check := range c.ready {
+			checks[name] = check
+		}
+	}
+	c.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusOK {
+				report.Status = StatusDegraded
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// run runs the check, failing it if it doesn't complete before ctx is done.
+func run(ctx context.Context, check Check) Result {
+	done := make(chan Result, 1)
+	go func() {
+		detail, err := check(ctx)
+		result := Result{Status: StatusOK, Detail: detail}
+		if err != nil {
+			result.Status = StatusDegraded
+			result.Error = err.Error()
+		}
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		return result
+	case <-ctx.Done():
+		return Result{Status: StatusDegraded, Error: ctx.Err().Error()}
+	}
+}
+
+// ServeHealth serves the liveness checks, with status 503 if any fails.
+func (c *Checker) ServeHealth(w http.ResponseWriter, r *http.Request) {
+	c.serve(w, r, false)
+}
+
+// ServeReady serves the liveness and readiness checks, with status 503 if any fails.
+func (c *Checker) ServeReady(w http.ResponseWriter, r *http.Request) {
+	c.serve(w, r, true)
+}
+
+func (c *Checker) serve(w http.ResponseWriter, r *http.Request, ready bool) {
+	report := c.Run(r.Context(), ready)
+
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status != StatusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(report)
+}
+
+// Handle registers /healthz and /readyz on the mux.
+func (c *Checker) Handle(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.ServeHealth)
+	mux.HandleFunc("/readyz", c.ServeReady)
+}
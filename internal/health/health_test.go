@@ -0,0 +1,80 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServeHealthAndReady(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Live("generator", func(ctx context.Context) (string, error) {
+		return "running", nil
+	})
+	checker.Ready("database", func(ctx context.Context) (string, error) {
+		return "", errors.New("connection refused")
+	})
+
+	rec := httptest.NewRecorder()
+	checker.ServeHealth(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 from /healthz, got %d", rec.Code)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	if report.Status != StatusOK || len(report.Checks) != 1 || report.Checks["generator"].Detail != "running" {
+		t.Errorf("Unexpected /healthz report: %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	checker.ServeReady(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503 from /readyz, got %d", rec.Code)
+	}
+	report = Report{}
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatal(err)
+	}
+	database := report.Checks["database"]
+	if report.Status != StatusDegraded || database.Status != StatusDegraded || database.Error != "connection refused" {
+		t.Errorf("Unexpected /readyz report: %+v", report)
+	}
+	if report.Checks["generator"].Status != StatusOK {
+		t.Errorf("Expected /readyz to include liveness checks, got %+v", report)
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	checker := NewChecker(10 * time.Millisecond)
+	checker.Ready("broker", func(ctx context.Context) (string, error) {
+		time.Sleep(time.Second)
+		return "", nil
+	})
+
+	start := time.Now()
+	report := checker.Run(context.Background(), true)
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected the check to time out, took %s", time.Since(start))
+	}
+	if report.Checks["broker"].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("Expected deadline exceeded, got %+v", report.Checks["broker"])
+	}
+}
+
+func TestDuplicateCheck(t *testing.T) {
+	checker := NewChecker(time.Second)
+	checker.Ready("database", func(ctx context.Context) (string, error) { return "", nil })
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected a panic registering a second database check")
+		}
+	}()
+	checker.Live("database", func(ctx context.Context) (string, error) { return "", nil })
+}
@@ -0,0 +1,63 @@
+package ledger
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// defaultHistoryLimit is the number of transactions returned without a limit parameter.
+const defaultHistoryLimit = 50
+
+// ServeBalance handles HTTP requests to retrieve the wallet balances of a player.
+func (l *Ledger) ServeBalance(w http.ResponseWriter, r *http.Request, playerID int) {
+	balances, err := l.Balances(r.Context(), playerID)
+	if err != nil {
+		http.Error(w, "failed to fetch balances", http.StatusInternalServerError)
+		return
+	}
+	if balances == nil {
+		balances = []Balance{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(struct {
+		PlayerID int       `json:"player_id"`
+		Balances []Balance `json:"balances"`
+	}{playerID, balances})
+	if err != nil {
+		return
+	}
+}
+
+// ServeHistory handles HTTP requests to retrieve the ledger history of a player.
+// The number of transactions can be set with the limit query parameter.
+func (l *Ledger) ServeHistory(w http.ResponseWriter, r *http.Request, playerID int) {
+	limit := defaultHistoryLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 1000 {
+			http.Error(w, "limit must be an integer between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	history, err := l.History(r.Context(), playerID, limit)
+	if err != nil {
+		http.Error(w, "failed to fetch ledger history", http.StatusInternalServerError)
+		return
+	}
+	if history == nil {
+		history = []Transaction{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(struct {
+		PlayerID     int           `json:"player_id"`
+		Transactions []Transaction `json:"transactions"`
+	}{playerID, history})
+	if err != nil {
+		return
+	}
+}
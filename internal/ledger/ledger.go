@@ -0,0 +1,185 @@
+// Package ledger This keeps a double-entry ledger of player wallets and their balances.
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// Transaction kinds.
+const (
+	KindDeposit = "deposit"
+	KindBet     = "bet"
+	KindWin     = "win"
+)
+
+// Policy decides what happens to bets that would overdraw a wallet.
+type Policy string
+
+const (
+	// PolicyFlag applies the bet and flags the transaction.
+	PolicyFlag Policy = "flag"
+	// PolicyReject refuses the bet with ErrInsufficientFunds.
+	PolicyReject Policy = "reject"
+)
+
+// ParsePolicy parses an overdraw policy, treating an empty string as PolicyFlag.
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "", PolicyFlag:
+		return PolicyFlag, nil
+	case PolicyReject:
+		return PolicyReject, nil
+	}
+	return "", fmt.Errorf("unknown overdraw policy %q", s)
+}
+
+var (
+	// ErrInsufficientFunds is returned for bets exceeding the balance under PolicyReject.
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	// ErrDuplicate is returned when the event was already applied.
+	ErrDuplicate = errors.New("event already applied")
+)
+
+// Entry is one side of a transaction. Debits are negative, credits positive,
+// and the entries of a transaction always sum to zero.
+type Entry struct {
+	Account string `json:"account"`
+	Amount  int    `json:"amount"`
+}
+
+// Transaction moves an amount between a player wallet and the house.
+type Transaction struct {
+	ID             int64     `json:"id"`
+	EventID        int       `json:"event_id"`
+	EventCreatedAt time.Time `json:"event_created_at"`
+	PlayerID       int       `json:"player_id"`
+	Currency       string    `json:"currency"`
+	Kind           string    `json:"kind"`
+	Amount         int       `json:"amount"`
+	BalanceAfter   int       `json:"balance_after"`
+	Flagged        bool      `json:"flagged,omitempty"`
+	Entries        []Entry   `json:"entries"`
+}
+
+// Balance is the balance of a player wallet in a currency, in the smallest unit.
+type Balance struct {
+	PlayerID int    `json:"player_id"`
+	Currency string `json:"currency"`
+	Balance  int    `json:"balance"`
+}
+
+// CheckFunc validates a transaction against the current wallet balance and
+// reports whether it should be flagged.
+type CheckFunc func(balance int) (flagged bool, err error)
+
+// Store persists transactions and balances.
+type Store interface {
+	// Post atomically checks the transaction against the wallet balance,
+	// records it and updates the balance. It returns ErrDuplicate if a
+	// transaction for the same event and kind already exists.
+	Post(ctx context.Context, tx Transaction, check CheckFunc) (Transaction, error)
+	// Balances returns the wallet balances of a player.
+	Balances(ctx context.Context, playerID int) ([]Balance, error)
+	// History returns the most recent transactions of a player, newest first.
+	History(ctx context.Context, playerID, limit int) ([]Transaction, error)
+}
+
+// Ledger applies events to player wallets.
+type Ledger struct {
+	store  Store
+	policy Policy
+}
+
+// NewLedger creates a new Ledger.
+func NewLedger(store Store, policy Policy) *Ledger {
+	return &Ledger{store: store, policy: policy}
+}
+
+// Apply records the wallet movement of an event: deposits credit the wallet,
+// bets debit it and the payout of a winning bet credits it again. Events that
+// don't move money are ignored and return nil.
+func (l *Ledger) Apply(ctx context.Context, event casino.Event) ([]Transaction, error) {
+	var txs []Transaction
+
+	switch event.Type {
+	case "deposit":
+		tx, err := l.post(ctx, event, KindDeposit, event.Amount)
+		if err != nil {
+			return txs, err
+		}
+		txs = append(txs, tx)
+	case "bet":
+		tx, err := l.post(ctx, event, KindBet, -event.Amount)
+		if err != nil && !errors.Is(err, ErrDuplicate) {
+			return txs, err
+		}
+		if err == nil {
+			txs = append(txs, tx)
+		}
+
+		// A redelivered bet may still be missing its payout, so the win is
+		// posted even if the bet was already applied.
+		if event.HasWon && event.WinAmount > 0 {
+			win, winErr := l.post(ctx, event, KindWin, event.WinAmount)
+			if winErr != nil {
+				return txs, winErr
+			}
+			txs = append(txs, win)
+		} else if err != nil {
+			return txs, err
+		}
+	}
+
+	return txs, nil
+}
+
+// Balances returns the wallet balances of a player.
+func (l *Ledger) Balances(ctx context.Context, playerID int) ([]Balance, error) {
+	return l.store.Balances(ctx, playerID)
+}
+
+// History returns the most recent transactions of a player, newest first.
+func (l *Ledger) History(ctx context.Context, playerID, limit int) ([]Transaction, error) {
+	return l.store.History(ctx, playerID, limit)
+}
+
+// post records a transaction changing the player wallet by amount, balanced by the house account.
+func (l *Ledger) post(ctx context.Context, event casino.Event, kind string, amount int) (Transaction, error) {
+	tx := Transaction{
+		EventID:        event.ID,
+		EventCreatedAt: event.CreatedAt,
+		PlayerID:       event.PlayerID,
+		Currency:       event.Currency,
+		Kind:           kind,
+		Amount:         amount,
+		Entries: []Entry{
+			{Account: PlayerAccount(event.PlayerID, event.Currency), Amount: amount},
+			{Account: HouseAccount(event.Currency), Amount: -amount},
+		},
+	}
+
+	return l.store.Post(ctx, tx, func(balance int) (bool, error) {
+		if amount >= 0 || balance+amount >= 0 {
+			return false, nil
+		}
+		if l.policy == PolicyReject {
+			return false, fmt.Errorf("%w: balance %d %s, bet %d", ErrInsufficientFunds, balance, event.Currency, -amount)
+		}
+		return true, nil
+	})
+}
+
+// PlayerAccount returns the account name of a player wallet.
+func PlayerAccount(playerID int, currency string) string {
+	return fmt.Sprintf("player:%d:%s", playerID, currency)
+}
+
+// HouseAccount returns the account name of the house in a currency.
+func HouseAccount(currency string) string {
+	return "house:" + currency
+}
@@ -0,0 +1,145 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+func TestApply(t *testing.T) {
+	l := NewLedger(NewMemoryStore(), PolicyFlag)
+	ctx := context.Background()
+	now := time.Now()
+
+	events := []casino.Event{
+		{ID: 1, PlayerID: 10, Type: "deposit", Currency: "EUR", Amount: 1000, CreatedAt: now},
+		{ID: 2, PlayerID: 10, Type: "bet", Currency: "EUR", Amount: 300, CreatedAt: now},
+		{ID: 3, PlayerID: 10, Type: "game_start", CreatedAt: now},
+		{ID: 4, PlayerID: 10, Type: "deposit", Currency: "USD", Amount: 500, CreatedAt: now},
+	}
+	for _, event := range events {
+		if _, err := l.Apply(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	balances, _ := l.Balances(ctx, 10)
+	expected := []Balance{{10, "EUR", 700}, {10, "USD", 500}}
+	if len(balances) != len(expected) {
+		t.Fatalf("Expected %d balances, got %+v", len(expected), balances)
+	}
+	for i := range expected {
+		if balances[i] != expected[i] {
+			t.Errorf("Expected balance %+v, got %+v", expected[i], balances[i])
+		}
+	}
+
+	history, _ := l.History(ctx, 10, 10)
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 transactions, got %d", len(history))
+	}
+	if history[0].EventID != 4 || history[2].EventID != 1 {
+		t.Errorf("Expected newest transaction first, got %+v", history)
+	}
+	bet := history[1]
+	if bet.Kind != KindBet || bet.Amount != -300 || bet.BalanceAfter != 700 {
+		t.Errorf("Expected bet of -300 leaving 700, got %+v", bet)
+	}
+	var sum int
+	for _, entry := range bet.Entries {
+		sum += entry.Amount
+	}
+	if len(bet.Entries) != 2 || sum != 0 {
+		t.Errorf("Expected two balanced entries, got %+v", bet.Entries)
+	}
+
+	// Redelivered events are not applied again
+	_, err := l.Apply(ctx, events[1])
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestApplyWin(t *testing.T) {
+	l := NewLedger(NewMemoryStore(), PolicyFlag)
+	ctx := context.Background()
+	bet := casino.Event{ID: 1, PlayerID: 10, Type: "bet", Currency: "EUR", Amount: 100, HasWon: true, WinAmount: 250, CreatedAt: time.Now()}
+
+	txs, err := l.Apply(ctx, bet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 2 || txs[1].Kind != KindWin || txs[1].BalanceAfter != 150 {
+		t.Errorf("Expected bet and win leaving 150, got %+v", txs)
+	}
+
+	_, err = l.Apply(ctx, bet)
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestApplyOverdraw(t *testing.T) {
+	ctx := context.Background()
+	bet := casino.Event{ID: 1, PlayerID: 10, Type: "bet", Currency: "EUR", Amount: 100, CreatedAt: time.Now()}
+
+	flagging := NewLedger(NewMemoryStore(), PolicyFlag)
+	txs, err := flagging.Apply(ctx, bet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 || !txs[0].Flagged || txs[0].BalanceAfter != -100 {
+		t.Errorf("Expected flagged bet leaving -100, got %+v", txs)
+	}
+
+	rejecting := NewLedger(NewMemoryStore(), PolicyReject)
+	_, err = rejecting.Apply(ctx, bet)
+	if !errors.Is(err, ErrInsufficientFunds) {
+		t.Errorf("Expected ErrInsufficientFunds, got %v", err)
+	}
+	balances, _ := rejecting.Balances(ctx, 10)
+	if len(balances) != 0 {
+		t.Errorf("Expected rejected bet to leave no balance, got %+v", balances)
+	}
+}
+
+func TestServeHistory(t *testing.T) {
+	l := NewLedger(NewMemoryStore(), PolicyFlag)
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		event := casino.Event{ID: i, PlayerID: 10, Type: "deposit", Currency: "EUR", Amount: 100, CreatedAt: time.Now()}
+		if _, err := l.Apply(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/players/10/ledger?limit=2", nil)
+	rr := httptest.NewRecorder()
+	l.ServeHistory(rr, req, 10)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status code %d, got %d", http.StatusOK, rr.Code)
+	}
+	var response struct {
+		Transactions []Transaction `json:"transactions"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	if len(response.Transactions) != 2 || response.Transactions[0].BalanceAfter != 300 {
+		t.Errorf("Expected the 2 newest transactions, got %+v", response.Transactions)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/players/10/ledger?limit=abc", nil)
+	rr = httptest.NewRecorder()
+	l.ServeHistory(rr, req, 10)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status code %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
@@ -0,0 +1,90 @@
+package ledger
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps transactions and balances in memory.
+type MemoryStore struct {
+	mu           sync.Mutex
+	nextID       int64
+	transactions map[int][]Transaction
+	balances     map[int]map[string]int
+	applied      map[eventKey]bool
+}
+
+type eventKey struct {
+	eventID   int
+	createdAt time.Time
+	kind      string
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		transactions: make(map[int][]Transaction),
+		balances:     make(map[int]map[string]int),
+		applied:      make(map[eventKey]bool),
+	}
+}
+
+// Post checks and records the transaction.
+func (s *MemoryStore) Post(ctx context.Context, tx Transaction, check CheckFunc) (Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := eventKey{tx.EventID, tx.EventCreatedAt.UTC(), tx.Kind}
+	if s.applied[key] {
+		return Transaction{}, ErrDuplicate
+	}
+
+	balances, ok := s.balances[tx.PlayerID]
+	if !ok {
+		balances = make(map[string]int)
+		s.balances[tx.PlayerID] = balances
+	}
+
+	flagged, err := check(balances[tx.Currency])
+	if err != nil {
+		return Transaction{}, err
+	}
+
+	s.nextID++
+	tx.ID = s.nextID
+	tx.Flagged = flagged
+	balances[tx.Currency] += tx.Amount
+	tx.BalanceAfter = balances[tx.Currency]
+	s.transactions[tx.PlayerID] = append(s.transactions[tx.PlayerID], tx)
+	s.applied[key] = true
+
+	return tx, nil
+}
+
+// Balances returns the wallet balances of a player, ordered by currency.
+func (s *MemoryStore) Balances(ctx context.Context, playerID int) ([]Balance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var balances []Balance
+	for currency, balance := range s.balances[playerID] {
+		balances = append(balances, Balance{PlayerID: playerID, Currency: currency, Balance: balance})
+	}
+	sort.Slice(balances, func(i, j int) bool { return balances[i].Currency < balances[j].Currency })
+	return balances, nil
+}
+
+// History returns the most recent transactions of a player, newest first.
+func (s *MemoryStore) History(ctx context.Context, playerID, limit int) ([]Transaction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := s.transactions[playerID]
+	var history []Transaction
+	for i := len(all) - 1; i >= 0 && len(history) < limit; i-- {
+		history = append(history, all[i])
+	}
+	return history, nil
+}
@@ -0,0 +1,158 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// PostgresStore keeps transactions and balances in Postgres.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore.
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Close closes the database connection.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// Post checks and records the transaction, locking the wallet balance row
+// so concurrent transactions of the same wallet are serialized. Duplicates
+// are detected before the balance is checked.
+func (s *PostgresStore) Post(ctx context.Context, tx Transaction, check CheckFunc) (Transaction, error) {
+	dbTx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer dbTx.Rollback()
+
+	_, err = dbTx.ExecContext(ctx, `INSERT INTO wallet_balances (player_id, currency) VALUES ($1, $2) ON CONFLICT DO NOTHING`,
+		tx.PlayerID, tx.Currency)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("error creating wallet: %w", err)
+	}
+
+	var balance int
+	err = dbTx.QueryRowContext(ctx, `SELECT balance FROM wallet_balances WHERE player_id = $1 AND currency = $2 FOR UPDATE`,
+		tx.PlayerID, tx.Currency).Scan(&balance)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("error fetching balance: %w", err)
+	}
+
+	// Look for a previous delivery of the event before checking the balance,
+	// so redelivered events are duplicates rather than overdrafts. The wallet
+	// row lock serializes this with a concurrent delivery.
+	var exists bool
+	err = dbTx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM ledger_transactions WHERE event_id = $1 AND event_created_at = $2 AND kind = $3)`,
+		tx.EventID, tx.EventCreatedAt, tx.Kind).Scan(&exists)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("error checking for duplicate transaction: %w", err)
+	}
+	if exists {
+		return Transaction{}, ErrDuplicate
+	}
+
+	flagged, err := check(balance)
+	if err != nil {
+		return Transaction{}, err
+	}
+	tx.Flagged = flagged
+	tx.BalanceAfter = balance + tx.Amount
+
+	query := `INSERT INTO ledger_transactions (event_id, event_created_at, player_id, currency, kind, amount, balance_after, flagged)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (event_id, event_created_at, kind) DO NOTHING
+		RETURNING id`
+	err = dbTx.QueryRowContext(ctx, query, tx.EventID, tx.EventCreatedAt, tx.PlayerID, tx.Currency, tx.Kind,
+		tx.Amount, tx.BalanceAfter, tx.Flagged).Scan(&tx.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Transaction{}, ErrDuplicate
+	} else if err != nil {
+		return Transaction{}, fmt.Errorf("error recording transaction: %w", err)
+	}
+
+	for _, entry := range tx.Entries {
+		_, err = dbTx.ExecContext(ctx, `INSERT INTO ledger_entries (transaction_id, account, amount) VALUES ($1, $2, $3)`,
+			tx.ID, entry.Account, entry.Amount)
+		if err != nil {
+			return Transaction{}, fmt.Errorf("error recording entry: %w", err)
+		}
+	}
+
+	_, err = dbTx.ExecContext(ctx, `UPDATE wallet_balances SET balance = $3, updated_at = now() WHERE player_id = $1 AND currency = $2`,
+		tx.PlayerID, tx.Currency, tx.BalanceAfter)
+	if err != nil {
+		return Transaction{}, fmt.Errorf("error updating balance: %w", err)
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return Transaction{}, fmt.Errorf("error committing transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// Balances returns the wallet balances of a player, ordered by currency.
+func (s *PostgresStore) Balances(ctx context.Context, playerID int) ([]Balance, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT currency, balance FROM wallet_balances WHERE player_id = $1 ORDER BY currency`, playerID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching balances: %w", err)
+	}
+	defer rows.Close()
+
+	var balances []Balance
+	for rows.Next() {
+		b := Balance{PlayerID: playerID}
+		if err := rows.Scan(&b.Currency, &b.Balance); err != nil {
+			return nil, fmt.Errorf("error fetching balances: %w", err)
+		}
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// History returns the most recent transactions of a player, newest first.
+func (s *PostgresStore) History(ctx context.Context, playerID, limit int) ([]Transaction, error) {
+	query := `SELECT t.id, t.event_id, t.event_created_at, t.currency, t.kind, t.amount, t.balance_after, t.flagged, e.account, e.amount
+		FROM (SELECT * FROM ledger_transactions WHERE player_id = $1 ORDER BY id DESC LIMIT $2) t
+		JOIN ledger_entries e ON e.transaction_id = t.id
+		ORDER BY t.id DESC, e.account DESC`
+	rows, err := s.db.QueryContext(ctx, query, playerID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching ledger history: %w", err)
+	}
+	defer rows.Close()
+
+	var history []Transaction
+	for rows.Next() {
+		tx := Transaction{PlayerID: playerID}
+		var entry Entry
+		err := rows.Scan(&tx.ID, &tx.EventID, &tx.EventCreatedAt, &tx.Currency, &tx.Kind, &tx.Amount,
+			&tx.BalanceAfter, &tx.Flagged, &entry.Account, &entry.Amount)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching ledger history: %w", err)
+		}
+
+		if n := len(history); n > 0 && history[n-1].ID == tx.ID {
+			history[n-1].Entries = append(history[n-1].Entries, entry)
+			continue
+		}
+		tx.Entries = []Entry{entry}
+		history = append(history, tx)
+	}
+	return history, rows.Err()
+}
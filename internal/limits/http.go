@@ -0,0 +1,70 @@
+package limits
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ServeLimits handles HTTP requests to read the limits of a player. GET
+// returns the limits in effect with their usage in the current periods.
+func (c *Checker) ServeLimits(w http.ResponseWriter, r *http.Request, playerID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.writeSettings(w, r, playerID)
+}
+
+// ServeUpdate handles HTTP requests to change the limits of a player. PUT
+// sets the given limits and, if present, the self-exclusion flag, see
+// Update, and returns the limits like ServeLimits. It must only be served
+// to operators.
+func (c *Checker) ServeUpdate(w http.ResponseWriter, r *http.Request, playerID int) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var update Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	for _, limit := range update.Limits {
+		if (limit.Kind != KindDeposit && limit.Kind != KindLoss) || !limit.Period.Valid() || limit.AmountEUR < 0 {
+			http.Error(w, "limits need a kind of deposit or loss, a period of daily, weekly or monthly and a non-negative amount_eur", http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := c.Update(r.Context(), playerID, update, time.Now())
+	if errors.Is(err, ErrNotFound) {
+		http.Error(w, "player not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "failed to update limits", http.StatusInternalServerError)
+		return
+	}
+	c.writeSettings(w, r, playerID)
+}
+
+// writeSettings responds with the limits of the player and their usage.
+func (c *Checker) writeSettings(w http.ResponseWriter, r *http.Request, playerID int) {
+	settings, err := c.Settings(r.Context(), playerID, time.Now())
+	if err != nil {
+		http.Error(w, "failed to fetch limits", http.StatusInternalServerError)
+		return
+	}
+	if settings.Limits == nil {
+		settings.Limits = []Limit{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err = json.NewEncoder(w).Encode(settings)
+	if err != nil {
+		return
+	}
+}
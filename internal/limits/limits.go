@@ -0,0 +1,308 @@
+// Package limits This enforces responsible gambling limits and self-exclusion on consumed events.
+package limits
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// Queue is the RabbitMQ queue breach events are published to.
+const Queue = "casino_limit_breaches"
+
+// Kinds of limits.
+const (
+	// KindDeposit limits the sum of deposits in EUR.
+	KindDeposit = "deposit"
+	// KindLoss limits the net loss in EUR: wagered minus won.
+	KindLoss = "loss"
+)
+
+// Types of breaches.
+const (
+	BreachLimit         = "limit_breach"
+	BreachSelfExclusion = "self_exclusion_breach"
+)
+
+// ErrDuplicate is returned when the event was already counted, e.g.
+// because it was redelivered, or the breach was already recorded.
+var ErrDuplicate = errors.New("event already counted")
+
+// ErrNotFound is returned when the limits of a player who doesn't exist
+// are changed.
+var ErrNotFound = errors.New("player not found")
+
+// Period is the length of the calendar period a limit applies to, in UTC.
+type Period string
+
+// Periods of limits.
+const (
+	Daily   Period = "daily"
+	Weekly  Period = "weekly"
+	Monthly Period = "monthly"
+)
+
+// Periods lists every period.
+var Periods = []Period{Daily, Weekly, Monthly}
+
+// Start returns the start of the period containing t. Weeks start on Monday.
+func (p Period) Start(t time.Time) time.Time {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch p {
+	case Weekly:
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case Monthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// Valid reports whether the period is known.
+func (p Period) Valid() bool {
+	return p == Daily || p == Weekly || p == Monthly
+}
+
+// Limit is the maximum amount in EUR of a kind within a period, with the
+// amount used in the current period when listed. A raised limit is pending
+// until PendingFrom, when PendingEUR replaces AmountEUR.
+type Limit struct {
+	Kind        string     `json:"kind"`
+	Period      Period     `json:"period"`
+	AmountEUR   int        `json:"amount_eur"`
+	UsedEUR     int        `json:"used_eur"`
+	PendingEUR  int        `json:"pending_amount_eur,omitempty"`
+	PendingFrom *time.Time `json:"pending_from,omitempty"`
+}
+
+// Settings are the limits and self-exclusion of a player. A lifted
+// self-exclusion lasts until SelfExclusionEnds.
+type Settings struct {
+	PlayerID          int        `json:"player_id"`
+	SelfExcluded      bool       `json:"self_excluded"`
+	SelfExclusionEnds *time.Time `json:"self_exclusion_ends,omitempty"`
+	Limits            []Limit    `json:"limits"`
+}
+
+// At returns the settings in effect at t, with the pending changes due by
+// then applied.
+func (s Settings) At(t time.Time) Settings {
+	if s.SelfExclusionEnds != nil && !t.Before(*s.SelfExclusionEnds) {
+		s.SelfExcluded, s.SelfExclusionEnds = false, nil
+	}
+	limits := make([]Limit, len(s.Limits))
+	for i, limit := range s.Limits {
+		if limit.PendingFrom != nil && !t.Before(*limit.PendingFrom) {
+			limit.AmountEUR, limit.PendingEUR, limit.PendingFrom = limit.PendingEUR, 0, nil
+		}
+		limits[i] = limit
+	}
+	if s.Limits != nil {
+		s.Limits = limits
+	}
+	return s
+}
+
+// Update changes the limits given and, if set, the self-exclusion of a player.
+type Update struct {
+	SelfExcluded *bool   `json:"self_excluded"`
+	Limits       []Limit `json:"limits"`
+}
+
+// Breach is published when a deposit or bet exceeds a limit, or is made by
+// a self-excluded player. Self-exclusion breaches have no kind or limit and
+// fall in the daily period.
+type Breach struct {
+	Type           string    `json:"type"`
+	PlayerID       int       `json:"player_id"`
+	EventID        int       `json:"event_id"`
+	EventType      string    `json:"event_type"`
+	EventCreatedAt time.Time `json:"event_created_at"`
+	Kind           string    `json:"kind,omitempty"`
+	Period         Period    `json:"period,omitempty"`
+	PeriodStart    time.Time `json:"period_start,omitempty"`
+	LimitEUR       int       `json:"limit_eur,omitempty"`
+	UsedEUR        int       `json:"used_eur,omitempty"`
+}
+
+// Usage is an amount counted towards the limits of a kind by an event.
+type Usage struct {
+	EventID        int
+	EventCreatedAt time.Time
+	PlayerID       int
+	Kind           string
+	AmountEUR      int
+}
+
+// Store keeps limits, self-exclusion and usage per period.
+type Store interface {
+	// Settings returns the limits and self-exclusion of the player, without usage.
+	Settings(ctx context.Context, playerID int) (Settings, error)
+	// Update calls apply with the settings of the player and saves the
+	// changed settings, atomically, or returns ErrNotFound if the store
+	// knows its players and the player doesn't exist.
+	Update(ctx context.Context, playerID int, apply func(*Settings)) error
+	// AddUsage adds the usage to the periods starting at the given times and
+	// returns the totals, or ErrDuplicate if the event was already counted.
+	AddUsage(ctx context.Context, usage Usage, starts map[Period]time.Time) (map[Period]int, error)
+	// Used returns the totals of a kind for the periods starting at the given times.
+	Used(ctx context.Context, playerID int, kind string, starts map[Period]time.Time) (map[Period]int, error)
+	// AddBreach records the breach, or returns ErrDuplicate if a breach of
+	// the same player, type, kind and period start was already recorded.
+	AddBreach(ctx context.Context, breach Breach) error
+}
+
+// Checker evaluates deposits and bets against the limits of their player.
+type Checker struct {
+	store      Store
+	coolingOff time.Duration
+}
+
+// NewChecker creates a new Checker. Raised limits and lifted self-exclusions
+// take effect after the cooling-off period.
+func NewChecker(store Store, coolingOff time.Duration) *Checker {
+	return &Checker{store: store, coolingOff: coolingOff}
+}
+
+// Update applies the update to the settings of the player at now. Lowered
+// and new limits and self-exclusion take effect immediately. Raised limits
+// and lifted self-exclusion take effect after the cooling-off period, so a
+// player can't undo them on impulse.
+func (c *Checker) Update(ctx context.Context, playerID int, update Update, now time.Time) error {
+	return c.store.Update(ctx, playerID, func(s *Settings) {
+		*s = s.At(now)
+		effective := now.Add(c.coolingOff)
+		for _, limit := range update.Limits {
+			i := indexLimit(s.Limits, limit.Kind, limit.Period)
+			if i < 0 {
+				s.Limits = append(s.Limits, Limit{Kind: limit.Kind, Period: limit.Period, AmountEUR: limit.AmountEUR})
+				continue
+			}
+			current := &s.Limits[i]
+			if limit.AmountEUR <= current.AmountEUR {
+				current.AmountEUR, current.PendingEUR, current.PendingFrom = limit.AmountEUR, 0, nil
+				continue
+			}
+			current.PendingEUR, current.PendingFrom = limit.AmountEUR, &effective
+		}
+
+		if update.SelfExcluded == nil {
+			return
+		}
+		if *update.SelfExcluded {
+			s.SelfExcluded, s.SelfExclusionEnds = true, nil
+		} else if s.SelfExcluded && s.SelfExclusionEnds == nil {
+			s.SelfExclusionEnds = &effective
+		}
+	})
+}
+
+// Record records the breach, or returns ErrDuplicate if it was already
+// recorded for the player, limit and period, so it is published once.
+func (c *Checker) Record(ctx context.Context, breach Breach) error {
+	return c.store.AddBreach(ctx, breach)
+}
+
+// Check counts the event towards the limits of its player and returns the
+// breaches it causes. Only deposits and bets are checked.
+func (c *Checker) Check(ctx context.Context, event casino.Event) ([]Breach, error) {
+	var usage Usage
+	switch event.Type {
+	case "deposit":
+		usage = Usage{Kind: KindDeposit, AmountEUR: event.AmountEUR}
+	case "bet":
+		usage = Usage{Kind: KindLoss, AmountEUR: event.AmountEUR - event.WinAmountEUR}
+	default:
+		return nil, nil
+	}
+	usage.EventID = event.ID
+	usage.EventCreatedAt = event.CreatedAt
+	usage.PlayerID = event.PlayerID
+
+	settings, err := c.store.Settings(ctx, event.PlayerID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching limits: %w", err)
+	}
+	settings = settings.At(event.CreatedAt)
+
+	starts := periodStarts(event.CreatedAt)
+	used, err := c.store.AddUsage(ctx, usage, starts)
+	if err != nil {
+		return nil, err
+	}
+
+	breach := Breach{
+		PlayerID:       event.PlayerID,
+		EventID:        event.ID,
+		EventType:      event.Type,
+		EventCreatedAt: event.CreatedAt,
+	}
+	var breaches []Breach
+	if settings.SelfExcluded {
+		b := breach
+		b.Type = BreachSelfExclusion
+		b.Period = Daily
+		b.PeriodStart = starts[Daily]
+		breaches = append(breaches, b)
+	}
+	for _, limit := range settings.Limits {
+		if limit.Kind != usage.Kind || used[limit.Period] <= limit.AmountEUR {
+			continue
+		}
+		b := breach
+		b.Type = BreachLimit
+		b.Kind = limit.Kind
+		b.Period = limit.Period
+		b.PeriodStart = starts[limit.Period]
+		b.LimitEUR = limit.AmountEUR
+		b.UsedEUR = used[limit.Period]
+		breaches = append(breaches, b)
+	}
+	return breaches, nil
+}
+
+// Settings returns the limits of the player in effect at now, with their
+// usage in the periods containing now.
+func (c *Checker) Settings(ctx context.Context, playerID int, now time.Time) (Settings, error) {
+	settings, err := c.store.Settings(ctx, playerID)
+	if err != nil {
+		return Settings{}, err
+	}
+	settings = settings.At(now)
+
+	starts := periodStarts(now)
+	used := make(map[string]map[Period]int)
+	for i, limit := range settings.Limits {
+		if used[limit.Kind] == nil {
+			used[limit.Kind], err = c.store.Used(ctx, playerID, limit.Kind, starts)
+			if err != nil {
+				return Settings{}, err
+			}
+		}
+		settings.Limits[i].UsedEUR = used[limit.Kind][limit.Period]
+	}
+	return settings, nil
+}
+
+// indexLimit returns the index of the limit of a kind and period, or -1.
+func indexLimit(limits []Limit, kind string, period Period) int {
+	for i, limit := range limits {
+		if limit.Kind == kind && limit.Period == period {
+			return i
+		}
+	}
+	return -1
+}
+
+// periodStarts returns the start of every period containing t.
+func periodStarts(t time.Time) map[Period]time.Time {
+	starts := make(map[Period]time.Time, len(Periods))
+	for _, p := range Periods {
+		starts[p] = p.Start(t)
+	}
+	return starts
+}
@@ -0,0 +1,214 @@
+package limits
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+func TestPeriodStart(t *testing.T) {
+	// Wednesday
+	at := time.Date(2025, 2, 19, 15, 30, 0, 0, time.UTC)
+	tests := []struct {
+		period Period
+		want   time.Time
+	}{
+		{Daily, time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)},
+		{Weekly, time.Date(2025, 2, 17, 0, 0, 0, 0, time.UTC)},
+		{Monthly, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := tt.period.Start(at); !got.Equal(tt.want) {
+			t.Errorf("Expected %s start %s, got %s", tt.period, tt.want, got)
+		}
+	}
+
+	// Sundays belong to the week starting on the Monday before.
+	sunday := time.Date(2025, 2, 23, 23, 0, 0, 0, time.UTC)
+	if got := Weekly.Start(sunday); !got.Equal(time.Date(2025, 2, 17, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Expected Sunday to belong to the week of Feb 17, got %s", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	checker := NewChecker(NewMemoryStore(), 24*time.Hour)
+	checker.Update(ctx, 10, Update{Limits: []Limit{
+		{Kind: KindDeposit, Period: Daily, AmountEUR: 1000},
+		{Kind: KindLoss, Period: Weekly, AmountEUR: 500},
+	}}, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC))
+
+	day := time.Date(2025, 2, 19, 10, 0, 0, 0, time.UTC)
+	events := []struct {
+		event casino.Event
+		want  []string
+	}{
+		{casino.Event{ID: 1, Type: "deposit", AmountEUR: 600, CreatedAt: day}, nil},
+		{casino.Event{ID: 2, Type: "deposit", AmountEUR: 600, CreatedAt: day.Add(time.Hour)}, []string{"deposit/daily"}},
+		// A new day resets the daily deposit limit.
+		{casino.Event{ID: 3, Type: "deposit", AmountEUR: 600, CreatedAt: day.Add(24 * time.Hour)}, nil},
+		{casino.Event{ID: 4, Type: "bet", AmountEUR: 400, CreatedAt: day}, nil},
+		// Wins reduce the loss.
+		{casino.Event{ID: 5, Type: "bet", AmountEUR: 400, HasWon: true, WinAmountEUR: 300, CreatedAt: day}, nil},
+		{casino.Event{ID: 6, Type: "bet", AmountEUR: 100, CreatedAt: day.Add(48 * time.Hour)}, []string{"loss/weekly"}},
+		{casino.Event{ID: 7, Type: "game_start", CreatedAt: day}, nil},
+	}
+	for _, e := range events {
+		e.event.PlayerID = 10
+		breaches, err := checker.Check(ctx, e.event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, b := range breaches {
+			got = append(got, b.Kind+"/"+string(b.Period))
+		}
+		if strings.Join(got, ",") != strings.Join(e.want, ",") {
+			t.Errorf("Event %d: expected breaches %v, got %v", e.event.ID, e.want, got)
+		}
+	}
+
+	// Redelivered events are counted once.
+	_, err := checker.Check(ctx, casino.Event{ID: 1, PlayerID: 10, Type: "deposit", AmountEUR: 600, CreatedAt: day})
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate, got %v", err)
+	}
+}
+
+func TestCheckSelfExcluded(t *testing.T) {
+	ctx := context.Background()
+	checker := NewChecker(NewMemoryStore(), 24*time.Hour)
+	checker.Update(ctx, 10, Update{SelfExcluded: &[]bool{true}[0]}, time.Now())
+
+	breaches, err := checker.Check(ctx, casino.Event{ID: 1, PlayerID: 10, Type: "bet", AmountEUR: 1, CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breaches) != 1 || breaches[0].Type != BreachSelfExclusion {
+		t.Errorf("Expected a self-exclusion breach, got %+v", breaches)
+	}
+}
+
+func TestCoolingOff(t *testing.T) {
+	ctx := context.Background()
+	checker := NewChecker(NewMemoryStore(), 24*time.Hour)
+	now := time.Date(2025, 2, 19, 10, 0, 0, 0, time.UTC)
+	excluded, lifted := true, false
+
+	checker.Update(ctx, 10, Update{SelfExcluded: &excluded, Limits: []Limit{{Kind: KindDeposit, Period: Daily, AmountEUR: 1000}}}, now)
+
+	// Lowering applies immediately, raising and lifting after the cooling-off period.
+	checker.Update(ctx, 10, Update{Limits: []Limit{{Kind: KindDeposit, Period: Daily, AmountEUR: 500}}}, now)
+	checker.Update(ctx, 10, Update{SelfExcluded: &lifted, Limits: []Limit{{Kind: KindDeposit, Period: Weekly, AmountEUR: 100}}}, now)
+	checker.Update(ctx, 10, Update{Limits: []Limit{{Kind: KindDeposit, Period: Weekly, AmountEUR: 5000}}}, now)
+
+	tests := []struct {
+		at       time.Time
+		excluded bool
+		weekly   int
+	}{
+		{now.Add(time.Hour), true, 100},
+		{now.Add(25 * time.Hour), false, 5000},
+	}
+	for _, tt := range tests {
+		settings, err := checker.Settings(ctx, 10, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		daily, weekly := settings.Limits[0], settings.Limits[1]
+		if settings.SelfExcluded != tt.excluded || daily.AmountEUR != 500 || weekly.AmountEUR != tt.weekly {
+			t.Errorf("At %s: unexpected settings %+v", tt.at, settings)
+		}
+	}
+
+	// A lifted self-exclusion still breaches until the cooling-off period ends.
+	breaches, err := checker.Check(ctx, casino.Event{ID: 1, PlayerID: 10, Type: "bet", AmountEUR: 1, CreatedAt: now.Add(time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breaches) != 1 || breaches[0].Type != BreachSelfExclusion {
+		t.Errorf("Expected a self-exclusion breach, got %+v", breaches)
+	}
+}
+
+func TestRecordBreachOnce(t *testing.T) {
+	ctx := context.Background()
+	checker := NewChecker(NewMemoryStore(), 24*time.Hour)
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+	breach := Breach{Type: BreachLimit, PlayerID: 10, EventID: 1, Kind: KindDeposit, Period: Daily, PeriodStart: day}
+
+	if err := checker.Record(ctx, breach); err != nil {
+		t.Fatal(err)
+	}
+	breach.EventID = 2
+	if err := checker.Record(ctx, breach); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("Expected ErrDuplicate for a second breach in the period, got %v", err)
+	}
+	breach.PeriodStart = day.AddDate(0, 0, 1)
+	if err := checker.Record(ctx, breach); err != nil {
+		t.Errorf("Expected a breach in the next period to be recorded, got %v", err)
+	}
+}
+
+func TestServeLimits(t *testing.T) {
+	checker := NewChecker(NewMemoryStore(), 24*time.Hour)
+
+	body := `{"self_excluded": true, "limits": [{"kind": "deposit", "period": "daily", "amount_eur": 1000}]}`
+	rec := httptest.NewRecorder()
+	checker.ServeLimits(rec, httptest.NewRequest(http.MethodPut, "/players/10/limits", strings.NewReader(body)), 10)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405 for PUT on the public route, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	checker.ServeUpdate(rec, httptest.NewRequest(http.MethodPut, "/players/10/limits", strings.NewReader(body)), 10)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+
+	checker.Check(context.Background(), casino.Event{ID: 1, PlayerID: 10, Type: "deposit", AmountEUR: 300, CreatedAt: time.Now()})
+
+	rec = httptest.NewRecorder()
+	checker.ServeLimits(rec, httptest.NewRequest(http.MethodGet, "/players/10/limits", nil), 10)
+	var settings Settings
+	if err := json.NewDecoder(rec.Body).Decode(&settings); err != nil {
+		t.Fatal(err)
+	}
+	if !settings.SelfExcluded || len(settings.Limits) != 1 || settings.Limits[0].UsedEUR != 300 {
+		t.Errorf("Unexpected settings: %+v", settings)
+	}
+
+	rec = httptest.NewRecorder()
+	body = `{"limits": [{"kind": "deposit", "period": "yearly", "amount_eur": 1000}]}`
+	checker.ServeUpdate(rec, httptest.NewRequest(http.MethodPut, "/players/10/limits", strings.NewReader(body)), 10)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400 for an unknown period, got %d", rec.Code)
+	}
+}
+
+// unknownPlayerStore is a store without any players.
+type unknownPlayerStore struct {
+	*MemoryStore
+}
+
+func (unknownPlayerStore) Update(ctx context.Context, playerID int, apply func(*Settings)) error {
+	return ErrNotFound
+}
+
+func TestServeUpdateUnknownPlayer(t *testing.T) {
+	checker := NewChecker(unknownPlayerStore{NewMemoryStore()}, 24*time.Hour)
+
+	body := `{"self_excluded": true}`
+	rec := httptest.NewRecorder()
+	checker.ServeUpdate(rec, httptest.NewRequest(http.MethodPut, "/admin/players/10/limits", strings.NewReader(body)), 10)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status 404 for an unknown player, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,126 @@
+package limits
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps limits and usage in memory.
+type MemoryStore struct {
+	mu       sync.Mutex
+	settings map[int]Settings
+	usage    map[usageKey]int
+	counted  map[eventKey]bool
+	breached map[breachKey]bool
+}
+
+type usageKey struct {
+	playerID int
+	kind     string
+	period   Period
+	start    time.Time
+}
+
+type eventKey struct {
+	eventID   int
+	createdAt time.Time
+	kind      string
+}
+
+type breachKey struct {
+	playerID int
+	typ      string
+	kind     string
+	period   Period
+	start    time.Time
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		settings: make(map[int]Settings),
+		usage:    make(map[usageKey]int),
+		counted:  make(map[eventKey]bool),
+		breached: make(map[breachKey]bool),
+	}
+}
+
+// Settings returns the limits and self-exclusion of the player.
+func (s *MemoryStore) Settings(ctx context.Context, playerID int) (Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.get(playerID), nil
+}
+
+// Update applies the changes to the settings of the player under the lock.
+// The store doesn't know the players, so every player exists.
+func (s *MemoryStore) Update(ctx context.Context, playerID int, apply func(*Settings)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	settings := s.get(playerID)
+	apply(&settings)
+	settings.PlayerID = playerID
+	s.settings[playerID] = settings
+	return nil
+}
+
+// get returns a copy of the settings of the player, with the limits sorted
+// like the postgres store does. Callers hold mu.
+func (s *MemoryStore) get(playerID int) Settings {
+	settings := s.settings[playerID]
+	settings.PlayerID = playerID
+	settings.Limits = append([]Limit(nil), settings.Limits...)
+	sort.Slice(settings.Limits, func(i, j int) bool {
+		a, b := settings.Limits[i], settings.Limits[j]
+		return a.Kind < b.Kind || (a.Kind == b.Kind && a.Period < b.Period)
+	})
+	return settings
+}
+
+// AddUsage adds the usage to the periods and returns the totals.
+func (s *MemoryStore) AddUsage(ctx context.Context, usage Usage, starts map[Period]time.Time) (map[Period]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := eventKey{usage.EventID, usage.EventCreatedAt.UTC(), usage.Kind}
+	if s.counted[key] {
+		return nil, ErrDuplicate
+	}
+	s.counted[key] = true
+
+	used := make(map[Period]int, len(starts))
+	for period, start := range starts {
+		k := usageKey{usage.PlayerID, usage.Kind, period, start}
+		s.usage[k] += usage.AmountEUR
+		used[period] = s.usage[k]
+	}
+	return used, nil
+}
+
+// Used returns the totals of a kind for the periods.
+func (s *MemoryStore) Used(ctx context.Context, playerID int, kind string, starts map[Period]time.Time) (map[Period]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	used := make(map[Period]int, len(starts))
+	for period, start := range starts {
+		used[period] = s.usage[usageKey{playerID, kind, period, start}]
+	}
+	return used, nil
+}
+
+// AddBreach records the breach once per player, type, kind and period start.
+func (s *MemoryStore) AddBreach(ctx context.Context, breach Breach) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := breachKey{breach.PlayerID, breach.Type, breach.Kind, breach.Period, breach.PeriodStart.UTC()}
+	if s.breached[key] {
+		return ErrDuplicate
+	}
+	s.breached[key] = true
+	return nil
+}
@@ -0,0 +1,221 @@
+package limits
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// PostgresStore keeps limits and usage in Postgres.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a new PostgresStore.
+func NewPostgresStore(databaseURL string) (*PostgresStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Close closes the database connection.
+func (s *PostgresStore) Close() error {
+	return s.db.Close()
+}
+
+// queryer is a database or transaction.
+type queryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Settings returns the limits and self-exclusion of the player. Players
+// missing from the players table are not self-excluded.
+func (s *PostgresStore) Settings(ctx context.Context, playerID int) (Settings, error) {
+	settings, _, err := readSettings(ctx, s.db, playerID, "")
+	return settings, err
+}
+
+// Update reads the settings of the player with their rows locked, applies
+// the changes and saves every limit and the self-exclusion in one
+// transaction. It returns ErrNotFound, without writing anything, if the
+// player isn't in the players table.
+func (s *PostgresStore) Update(ctx context.Context, playerID int, apply func(*Settings)) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	settings, found, err := readSettings(ctx, tx, playerID, " FOR UPDATE")
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	before := settings
+	apply(&settings)
+
+	if settings.SelfExcluded != before.SelfExcluded || !equalTime(settings.SelfExclusionEnds, before.SelfExclusionEnds) {
+		_, err := tx.ExecContext(ctx, `UPDATE players SET self_excluded = $2, self_exclusion_ends = $3 WHERE id = $1`,
+			playerID, settings.SelfExcluded, nullTime(settings.SelfExclusionEnds))
+		if err != nil {
+			return fmt.Errorf("error setting self-exclusion: %w", err)
+		}
+	}
+
+	for _, limit := range settings.Limits {
+		pending := sql.NullInt64{Int64: int64(limit.PendingEUR), Valid: limit.PendingFrom != nil}
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO player_limits (player_id, kind, period, amount_eur, pending_amount_eur, pending_from) VALUES ($1, $2, $3, $4, $5, $6)
+			ON CONFLICT (player_id, kind, period) DO UPDATE SET amount_eur = EXCLUDED.amount_eur,
+				pending_amount_eur = EXCLUDED.pending_amount_eur, pending_from = EXCLUDED.pending_from, updated_at = now()`,
+			playerID, limit.Kind, limit.Period, limit.AmountEUR, pending, nullTime(limit.PendingFrom))
+		if err != nil {
+			return fmt.Errorf("error setting limit: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing limits: %w", err)
+	}
+	return nil
+}
+
+// readSettings reads the settings of the player, and whether the player is
+// in the players table. The suffix is appended to the queries, e.g. to lock
+// the rows.
+func readSettings(ctx context.Context, q queryer, playerID int, suffix string) (Settings, bool, error) {
+	settings := Settings{PlayerID: playerID}
+	var ends sql.NullTime
+	found := true
+	err := q.QueryRowContext(ctx, `SELECT self_excluded, self_exclusion_ends FROM players WHERE id = $1`+suffix, playerID).
+		Scan(&settings.SelfExcluded, &ends)
+	if errors.Is(err, sql.ErrNoRows) {
+		found = false
+	} else if err != nil {
+		return Settings{}, false, fmt.Errorf("error fetching self-exclusion: %w", err)
+	}
+	if ends.Valid {
+		settings.SelfExclusionEnds = &ends.Time
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT kind, period, amount_eur, pending_amount_eur, pending_from FROM player_limits
+		WHERE player_id = $1 ORDER BY kind, period`+suffix, playerID)
+	if err != nil {
+		return Settings{}, false, fmt.Errorf("error fetching limits: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var limit Limit
+		var pending sql.NullInt64
+		var from sql.NullTime
+		if err := rows.Scan(&limit.Kind, &limit.Period, &limit.AmountEUR, &pending, &from); err != nil {
+			return Settings{}, false, fmt.Errorf("error scanning limit: %w", err)
+		}
+		if from.Valid {
+			limit.PendingEUR, limit.PendingFrom = int(pending.Int64), &from.Time
+		}
+		settings.Limits = append(settings.Limits, limit)
+	}
+	return settings, found, rows.Err()
+}
+
+// AddUsage adds the usage to the periods and returns the totals, marking
+// the event as counted in the same transaction.
+func (s *PostgresStore) AddUsage(ctx context.Context, usage Usage, starts map[Period]time.Time) (map[Period]int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO limit_events (event_id, event_created_at, kind) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING`,
+		usage.EventID, usage.EventCreatedAt, usage.Kind)
+	if err != nil {
+		return nil, fmt.Errorf("error marking event: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 0 {
+		return nil, ErrDuplicate
+	}
+
+	used := make(map[Period]int, len(starts))
+	for period, start := range starts {
+		var total int
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO limit_usage (player_id, kind, period, period_start, amount_eur) VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (player_id, kind, period, period_start) DO UPDATE SET amount_eur = limit_usage.amount_eur + EXCLUDED.amount_eur
+			RETURNING amount_eur`,
+			usage.PlayerID, usage.Kind, period, start, usage.AmountEUR).Scan(&total)
+		if err != nil {
+			return nil, fmt.Errorf("error adding usage: %w", err)
+		}
+		used[period] = total
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("error committing usage: %w", err)
+	}
+	return used, nil
+}
+
+// Used returns the totals of a kind for the periods.
+func (s *PostgresStore) Used(ctx context.Context, playerID int, kind string, starts map[Period]time.Time) (map[Period]int, error) {
+	used := make(map[Period]int, len(starts))
+	for period, start := range starts {
+		var total int
+		err := s.db.QueryRowContext(ctx, `
+			SELECT amount_eur FROM limit_usage WHERE player_id = $1 AND kind = $2 AND period = $3 AND period_start = $4`,
+			playerID, kind, period, start).Scan(&total)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("error fetching usage: %w", err)
+		}
+		used[period] = total
+	}
+	return used, nil
+}
+
+// AddBreach records the breach once per player, type, kind and period start.
+func (s *PostgresStore) AddBreach(ctx context.Context, breach Breach) error {
+	res, err := s.db.ExecContext(ctx, `
+		INSERT INTO limit_breaches (player_id, type, kind, period, period_start, event_id, event_created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING`,
+		breach.PlayerID, breach.Type, breach.Kind, breach.Period, breach.PeriodStart, breach.EventID, breach.EventCreatedAt)
+	if err != nil {
+		return fmt.Errorf("error recording breach: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrDuplicate
+	}
+	return nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: *t, Valid: true}
+}
+
+func equalTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
@@ -0,0 +1,273 @@
+package materialize
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/sketch"
+)
+
+// Mode selects whether a metric is tracked exactly or approximately.
+type Mode string
+
+const (
+	// ModeExact keeps an entry per player, so memory grows with the number of players.
+	ModeExact Mode = "exact"
+	// ModeApprox uses a sketch with bounded memory and a configurable error.
+	ModeApprox Mode = "approx"
+)
+
+// ParseMode parses a mode, treating an empty string as ModeExact.
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case "", ModeExact:
+		return ModeExact, nil
+	case ModeApprox:
+		return ModeApprox, nil
+	}
+	return "", fmt.Errorf("unknown mode %q", s)
+}
+
+// Options configures how each metric is tracked.
+type Options struct {
+	TopPlayerBets     Mode
+	TopPlayerWins     Mode
+	TopPlayerDeposits Mode
+	UniquePlayers     Mode
+
+	// HeavyHittersError bounds the overestimate of approximate top player
+	// counts as a fraction of the total, e.g. 0.001 for 0.1%.
+	HeavyHittersError float64
+
+	// UniquePlayersError is the standard error of approximate unique
+	// player counts, e.g. 0.01 for 1%.
+	UniquePlayersError float64
+
+	// MaxPlayerProfiles bounds the number of player profiles kept when any
+	// metric is tracked approximately. The least recently active players are
+	// evicted first. Zero keeps every player.
+	MaxPlayerProfiles int
+
+	// Addr is the listen address of StartHTTPServer.
+	Addr string
+
+	// StreamInterval is how often stream clients receive updated data.
+	StreamInterval time.Duration
+}
+
+// DefaultOptions returns options tracking every metric exactly.
+func DefaultOptions() Options {
+	return Options{
+		TopPlayerBets:      ModeExact,
+		TopPlayerWins:      ModeExact,
+		TopPlayerDeposits:  ModeExact,
+		UniquePlayers:      ModeExact,
+		HeavyHittersError:  0.001,
+		UniquePlayersError: 0.01,
+		MaxPlayerProfiles:  100000,
+		Addr:               ":8080",
+		StreamInterval:     time.Second,
+	}
+}
+
+// approximate reports whether any metric is tracked approximately, in which
+// case memory is expected to stay bounded regardless of the number of players.
+func (o Options) approximate() bool {
+	for _, mode := range []Mode{o.TopPlayerBets, o.TopPlayerWins, o.TopPlayerDeposits, o.UniquePlayers} {
+		if mode == ModeApprox {
+			return true
+		}
+	}
+	return false
+}
+
+// playerCounter tracks per-player totals to find the top player. The top
+// approximate counter is kept up to date on every add, so reading it doesn't
+// sort the sketch.
+type playerCounter struct {
+	exact  map[int]int
+	approx *sketch.SpaceSaving
+	best   sketch.SpaceSavingCounter
+}
+
+func newPlayerCounter(mode Mode, maxErr float64) *playerCounter {
+	if mode == ModeApprox {
+		return &playerCounter{approx: sketch.NewSpaceSaving(maxErr)}
+	}
+	return &playerCounter{exact: make(map[int]int)}
+}
+
+// restorePlayerCounter rebuilds a counter in the given mode from either
+// exact or approximate snapshot data, converting between them if needed.
+func restorePlayerCounter(mode Mode, maxErr float64, exact map[int]int, approx *sketch.SpaceSaving) *playerCounter {
+	c := newPlayerCounter(mode, maxErr)
+	switch {
+	case mode == ModeApprox && approx != nil:
+		c.approx = approx
+		c.best, _ = approx.Max()
+	case mode == ModeExact && approx != nil:
+		for _, counter := range approx.Top(approx.Capacity()) {
+			c.add(counter.Item, counter.Count)
+		}
+	default:
+		for id, n := range exact {
+			c.add(id, n)
+		}
+	}
+	return c
+}
+
+func (c *playerCounter) add(id, n int) {
+	if c.approx != nil {
+		c.approx.Add(id, n)
+		c.updateBest(id)
+		return
+	}
+	c.exact[id] += n
+}
+
+// updateBest compares the counter of id with the top one. Counts only grow,
+// so only the added item can overtake it, unless the top item was evicted.
+func (c *playerCounter) updateBest(id int) {
+	if _, ok := c.approx.Counter(c.best.Item); !ok {
+		c.best, _ = c.approx.Max()
+		return
+	}
+	counter, _ := c.approx.Counter(id)
+	if id == c.best.Item || counter.Count > c.best.Count || (counter.Count == c.best.Count && id < c.best.Item) {
+		c.best = counter
+	}
+}
+
+func (c *playerCounter) top() PlayerStats {
+	if c.approx != nil {
+		if _, ok := c.approx.Counter(c.best.Item); !ok {
+			return PlayerStats{Approximate: true}
+		}
+		return PlayerStats{ID: c.best.Item, Count: c.best.Count, Approximate: true}
+	}
+	return getTopPlayer(c.exact)
+}
+
+// uniqueSet counts distinct players exactly or with a HyperLogLog.
+type uniqueSet struct {
+	Exact map[int]struct{}    `json:"exact,omitempty"`
+	HLL   *sketch.HyperLogLog `json:"hll,omitempty"`
+}
+
+func newUniqueSet(mode Mode, stdErr float64) uniqueSet {
+	if mode == ModeApprox {
+		return uniqueSet{HLL: sketch.NewHyperLogLog(stdErr)}
+	}
+	return uniqueSet{Exact: make(map[int]struct{})}
+}
+
+func (s *uniqueSet) add(id int) {
+	if s.HLL != nil {
+		s.HLL.Add(id)
+		return
+	}
+	if s.Exact == nil {
+		s.Exact = make(map[int]struct{})
+	}
+	s.Exact[id] = struct{}{}
+}
+
+func (s uniqueSet) clone() uniqueSet {
+	if s.HLL != nil {
+		return uniqueSet{HLL: s.HLL.Clone()}
+	}
+	if s.Exact == nil {
+		return uniqueSet{}
+	}
+	exact := make(map[int]struct{}, len(s.Exact))
+	for id := range s.Exact {
+		exact[id] = struct{}{}
+	}
+	return uniqueSet{Exact: exact}
+}
+
+func (s *uniqueSet) count() int {
+	if s.HLL != nil {
+		return s.HLL.Count()
+	}
+	return len(s.Exact)
+}
+
+// uniqueBucket holds the distinct players seen within a single minute.
+type uniqueBucket struct {
+	Minute int64 `json:"minute"`
+	uniqueSet
+}
+
+// uniqueTracker counts distinct players overall and over the last hour.
+type uniqueTracker struct {
+	Mode    Mode                        `json:"mode"`
+	StdErr  float64                     `json:"std_err"`
+	Total   uniqueSet                   `json:"total"`
+	Buckets [windowBuckets]uniqueBucket `json:"buckets"`
+
+	// newest is the newest minute added, found from the buckets after a restore.
+	newest      int64
+	newestKnown bool
+}
+
+func newUniqueTracker(mode Mode, stdErr float64) *uniqueTracker {
+	return &uniqueTracker{Mode: mode, StdErr: stdErr, Total: newUniqueSet(mode, stdErr)}
+}
+
+func (u *uniqueTracker) clone() *uniqueTracker {
+	c := *u
+	c.Total = u.Total.clone()
+	for i := range c.Buckets {
+		c.Buckets[i].uniqueSet = u.Buckets[i].clone()
+	}
+	return &c
+}
+
+func (u *uniqueTracker) add(id int, t time.Time) {
+	u.Total.add(id)
+
+	// Players a whole window or more behind the newest minute are too late
+	// to count, and would reset the slot of a current minute.
+	minute := minuteOf(t)
+	if !u.newestKnown {
+		for _, b := range u.Buckets {
+			if (b.Exact != nil || b.HLL != nil) && (!u.newestKnown || b.Minute > u.newest) {
+				u.newest, u.newestKnown = b.Minute, true
+			}
+		}
+	}
+	if u.newestKnown && minute <= u.newest-windowBuckets {
+		return
+	}
+	if !u.newestKnown || minute > u.newest {
+		u.newest, u.newestKnown = minute, true
+	}
+
+	b := &u.Buckets[bucketIndex(minute)]
+	if b.Minute != minute {
+		*b = uniqueBucket{Minute: minute, uniqueSet: newUniqueSet(u.Mode, u.StdErr)}
+	}
+	b.add(id)
+}
+
+// lastHour returns the number of distinct players in the last hour relative to now.
+func (u *uniqueTracker) lastHour(now time.Time) int {
+	current := minuteOf(now)
+	union := newUniqueSet(u.Mode, u.StdErr)
+	for _, b := range u.Buckets {
+		if b.Minute <= current-windowBuckets || b.Minute > current {
+			continue
+		}
+		switch {
+		case b.HLL != nil && union.HLL != nil:
+			_ = union.HLL.Merge(b.HLL)
+		case b.Exact != nil && union.Exact != nil:
+			for id := range b.Exact {
+				union.Exact[id] = struct{}{}
+			}
+		}
+	}
+	return union.count()
+}
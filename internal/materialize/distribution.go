@@ -0,0 +1,144 @@
+package materialize
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/sketch"
+)
+
+// Distribution summarizes the amounts in EUR recorded by a histogram.
+type Distribution struct {
+	Count   uint64                   `json:"count"`
+	Mean    float64                  `json:"mean"`
+	Min     int                      `json:"min"`
+	P50     int                      `json:"p50"`
+	P90     int                      `json:"p90"`
+	P99     int                      `json:"p99"`
+	Max     int                      `json:"max"`
+	Buckets []sketch.HistogramBucket `json:"buckets"`
+}
+
+// AmountDistributions represents the distributions of an event type's amounts.
+type AmountDistributions struct {
+	Global     Distribution            `json:"global"`
+	ByGame     map[string]Distribution `json:"by_game,omitempty"`
+	ByCurrency map[string]Distribution `json:"by_currency"`
+}
+
+// Distributions represents the materialized amount distributions.
+type Distributions struct {
+	Bet     AmountDistributions `json:"bet"`
+	Deposit AmountDistributions `json:"deposit"`
+}
+
+// amountHistograms holds the histograms of an event type's amounts.
+type amountHistograms struct {
+	Global     *sketch.Histogram            `json:"global"`
+	ByGame     map[int]*sketch.Histogram    `json:"by_game,omitempty"`
+	ByCurrency map[string]*sketch.Histogram `json:"by_currency"`
+}
+
+func newAmountHistograms() *amountHistograms {
+	return &amountHistograms{
+		Global:     sketch.NewHistogram(),
+		ByGame:     make(map[int]*sketch.Histogram),
+		ByCurrency: make(map[string]*sketch.Histogram),
+	}
+}
+
+// record adds the amount in EUR of the event to the histograms.
+func (a *amountHistograms) record(event casino.Event, byGame bool) {
+	a.Global.Record(event.AmountEUR)
+
+	// Maps may be missing when restored from a snapshot.
+	if a.ByGame == nil {
+		a.ByGame = make(map[int]*sketch.Histogram)
+	}
+	if a.ByCurrency == nil {
+		a.ByCurrency = make(map[string]*sketch.Histogram)
+	}
+
+	if byGame {
+		h, ok := a.ByGame[event.GameID]
+		if !ok {
+			h = sketch.NewHistogram()
+			a.ByGame[event.GameID] = h
+		}
+		h.Record(event.AmountEUR)
+	}
+
+	h, ok := a.ByCurrency[event.Currency]
+	if !ok {
+		h = sketch.NewHistogram()
+		a.ByCurrency[event.Currency] = h
+	}
+	h.Record(event.AmountEUR)
+}
+
+func (a *amountHistograms) clone() *amountHistograms {
+	c := &amountHistograms{
+		Global:     a.Global.Clone(),
+		ByGame:     make(map[int]*sketch.Histogram, len(a.ByGame)),
+		ByCurrency: make(map[string]*sketch.Histogram, len(a.ByCurrency)),
+	}
+	for k, h := range a.ByGame {
+		c.ByGame[k] = h.Clone()
+	}
+	for k, h := range a.ByCurrency {
+		c.ByCurrency[k] = h.Clone()
+	}
+	return c
+}
+
+func (a *amountHistograms) summarize() AmountDistributions {
+	d := AmountDistributions{
+		Global:     summarize(a.Global),
+		ByCurrency: make(map[string]Distribution, len(a.ByCurrency)),
+	}
+	if len(a.ByGame) > 0 {
+		d.ByGame = make(map[string]Distribution, len(a.ByGame))
+		for id, h := range a.ByGame {
+			d.ByGame[strconv.Itoa(id)] = summarize(h)
+		}
+	}
+	for currency, h := range a.ByCurrency {
+		d.ByCurrency[currency] = summarize(h)
+	}
+	return d
+}
+
+func summarize(h *sketch.Histogram) Distribution {
+	return Distribution{
+		Count:   h.Total,
+		Mean:    h.Mean(),
+		Min:     h.Min,
+		P50:     h.Quantile(0.5),
+		P90:     h.Quantile(0.9),
+		P99:     h.Quantile(0.99),
+		Max:     h.Max,
+		Buckets: h.Buckets(),
+	}
+}
+
+// GetDistributions returns the current amount distributions.
+func (m *Materialize) GetDistributions() Distributions {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return Distributions{
+		Bet:     m.betAmounts.summarize(),
+		Deposit: m.depositAmounts.summarize(),
+	}
+}
+
+// GetDistributionsHandler handles HTTP requests to retrieve the amount distributions.
+func (m *Materialize) GetDistributionsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(m.GetDistributions())
+	if err != nil {
+		return
+	}
+}
@@ -1,12 +1,15 @@
 package materialize
 
 import (
-	"encoding/json"
+	"container/list"
+	"context"
+	"io"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
 	"github.com/rs/zerolog/log"
 )
 
@@ -17,13 +20,25 @@ type Stats struct {
 	EventsPerSecondMovingAvg float64     `json:"events_per_second_moving_average"`
 	TopPlayerBets            PlayerStats `json:"top_player_bets"`
 	TopPlayerWins            PlayerStats `json:"top_player_wins"`
+	TopPlayerWinsEUR         PlayerStats `json:"top_player_wins_eur"`
 	TopPlayerDeposits        PlayerStats `json:"top_player_deposits"`
+	UniquePlayersTotal       int         `json:"unique_players_total"`
+	UniquePlayersLastHour    int         `json:"unique_players_last_hour"`
+	WageredEURTotal          int         `json:"wagered_eur_total"`
+	WonEURTotal              int         `json:"won_eur_total"`
+	GGREUR                   int         `json:"ggr_eur"`
+	RTP                      float64     `json:"rtp"`
+
+	// Partial is set on filtered stats when events within the queried range
+	// are no longer kept, so the totals undercount.
+	Partial bool `json:"partial,omitempty"`
 }
 
 // PlayerStats represents the statistics for a player.
 type PlayerStats struct {
-	ID    int `json:"id"`
-	Count int `json:"count"`
+	ID          int  `json:"id"`
+	Count       int  `json:"count"`
+	Approximate bool `json:"approximate,omitempty"`
 }
 
 // Materialize holds the state and methods for materializing events.
@@ -31,29 +46,57 @@ type Materialize struct {
 	stats           Stats
 	mu              sync.Mutex
 	eventTimestamps []time.Time
-	playerBets      map[int]int
-	playerWins      map[int]int
-	playerDeposits  map[int]int
+	options         Options
+	playerBets      *playerCounter
+	playerWins      *playerCounter
+	playerWinsEUR   *playerCounter
+	playerDeposits  *playerCounter
+	uniquePlayers   *uniqueTracker
+	betAmounts      *amountHistograms
+	depositAmounts  *amountHistograms
+	recent          []recentEvent
+	recentTruncated time.Time
+	players         map[int]*playerState
+	playerOrder     *list.List
+	playerResources map[string]PlayerHandler
+	version         uint64
+	lastEventID     int
 }
 
-// NewMaterializer creates a new Materializer instance.
+// NewMaterializer creates a new Materializer instance tracking every metric exactly.
 func NewMaterialize() *Materialize {
-	return &Materialize{
-		playerBets:     make(map[int]int),
-		playerWins:     make(map[int]int),
-		playerDeposits: make(map[int]int),
+	return NewMaterializeWithOptions(DefaultOptions())
+}
+
+// NewMaterializeWithOptions creates a new Materializer instance with the given options.
+func NewMaterializeWithOptions(options Options) *Materialize {
+	m := &Materialize{
+		options:        options,
+		playerBets:     newPlayerCounter(options.TopPlayerBets, options.HeavyHittersError),
+		playerWins:     newPlayerCounter(options.TopPlayerWins, options.HeavyHittersError),
+		playerWinsEUR:  newPlayerCounter(options.TopPlayerWins, options.HeavyHittersError),
+		playerDeposits: newPlayerCounter(options.TopPlayerDeposits, options.HeavyHittersError),
+		uniquePlayers:  newUniqueTracker(options.UniquePlayers, options.UniquePlayersError),
+		betAmounts:     newAmountHistograms(),
+		depositAmounts: newAmountHistograms(),
+		players:        make(map[int]*playerState),
+		playerOrder:    newPlayerOrder(options),
 	}
+	m.playerResources = map[string]PlayerHandler{"stats": m.servePlayerStats}
+	return m
 }
 
 // UpdateStats updates the materialized data with the given event.
 // It increments the total event count, updates the moving average
 // of events per second over the last 60 seconds, and tracks the
-// top players by number of bets, wins, and sum of deposits in EUR.
+// top players by number of bets, wins, sum of wins and sum of deposits in EUR.
 func (m *Materialize) UpdateStats(event casino.Event) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	m.stats.EventsTotal++
+	m.version++
+	m.lastEventID = event.ID
 
 	// Track event timestamps for moving average calculation
 	m.eventTimestamps = append(m.eventTimestamps, time.Now())
@@ -73,48 +116,118 @@ func (m *Materialize) UpdateStats(event casino.Event) {
 	// Process event types
 	switch event.Type {
 	case "bet":
-		m.playerBets[event.PlayerID]++
-	case "game_stop":
+		m.playerBets.add(event.PlayerID, 1)
+		m.betAmounts.record(event, true)
+		m.stats.WageredEURTotal += event.AmountEUR
 		if event.HasWon {
-			m.playerWins[event.PlayerID]++
+			m.playerWins.add(event.PlayerID, 1)
+			m.playerWinsEUR.add(event.PlayerID, event.WinAmountEUR)
+			m.stats.WonEURTotal += event.WinAmountEUR
 		}
+		m.stats.GGREUR, m.stats.RTP = ggr(m.stats.WageredEURTotal, m.stats.WonEURTotal)
 	case "deposit":
-		m.playerDeposits[event.PlayerID] += event.AmountEUR // Track in EUR
+		m.playerDeposits.add(event.PlayerID, event.AmountEUR) // Track in EUR
+		m.depositAmounts.record(event, false)
 	}
 
+	// Keep the event for filtered queries
+	m.recordRecent(event, time.Now())
+
+	// Track unique players
+	m.uniquePlayers.add(event.PlayerID, event.CreatedAt)
+
+	// Update player profile
+	m.player(event.PlayerID).apply(event)
+
 	// Update top players
-	m.stats.TopPlayerBets = m.getTopPlayer(m.playerBets)
-	m.stats.TopPlayerWins = m.getTopPlayer(m.playerWins)
-	m.stats.TopPlayerDeposits = m.getTopPlayer(m.playerDeposits)
+	m.stats.TopPlayerBets = m.playerBets.top()
+	m.stats.TopPlayerWins = m.playerWins.top()
+	m.stats.TopPlayerWinsEUR = m.playerWinsEUR.top()
+	m.stats.TopPlayerDeposits = m.playerDeposits.top()
 }
 
-// GetStats handles HTTP requests to retrieve the materialized data.
-func (m *Materialize) GetStats(w http.ResponseWriter, r *http.Request) {
+// currentStats returns the stats with the unique player counts, which are
+// calculated on read rather than for every event. It must be called with m.mu held.
+func (m *Materialize) currentStats() Stats {
+	stats := m.stats
+	stats.UniquePlayersTotal = m.uniquePlayers.Total.count()
+	stats.UniquePlayersLastHour = m.uniquePlayers.lastHour(time.Now())
+	return stats
+}
+
+// snapshot returns a copy of the stats and the version they correspond to.
+func (m *Materialize) snapshot() (Stats, uint64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	return m.currentStats(), m.version
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(m.stats)
-	if err != nil {
-		return
-	}
+// EventsTotal returns the number of processed events.
+func (m *Materialize) EventsTotal() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stats.EventsTotal
+}
+
+// LastEventID returns the ID of the last processed event.
+func (m *Materialize) LastEventID() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastEventID
+}
+
+// Collect writes the materialized data as Prometheus metrics.
+func (m *Materialize) Collect(w io.Writer) {
+	stats, _ := m.snapshot()
+
+	metrics.WriteCounter(w, "casino_materialized_events_total", "Total number of materialized events.", float64(stats.EventsTotal))
+	metrics.WriteGauge(w, "casino_materialized_events_per_minute", "Number of events in the last minute.", stats.EventsPerMinute)
+	metrics.WriteGauge(w, "casino_materialized_events_per_second_moving_average", "Events per second as a moving average over the last minute.", stats.EventsPerSecondMovingAvg)
+	metrics.WriteGauge(w, "casino_materialized_top_player_bets", "Number of bets of the top player by bets.", float64(stats.TopPlayerBets.Count))
+	metrics.WriteGauge(w, "casino_materialized_top_player_wins", "Number of wins of the top player by wins.", float64(stats.TopPlayerWins.Count))
+	metrics.WriteGauge(w, "casino_materialized_top_player_wins_eur", "Sum of wins in EUR of the top player by sum of wins.", float64(stats.TopPlayerWinsEUR.Count))
+	metrics.WriteGauge(w, "casino_materialized_top_player_deposits_eur", "Sum of deposits in EUR of the top player by deposits.", float64(stats.TopPlayerDeposits.Count))
+	metrics.WriteGauge(w, "casino_materialized_unique_players_total", "Number of distinct players seen.", float64(stats.UniquePlayersTotal))
+	metrics.WriteGauge(w, "casino_materialized_unique_players_last_hour", "Number of distinct players seen in the last hour.", float64(stats.UniquePlayersLastHour))
+	metrics.WriteCounter(w, "casino_materialized_wagered_eur_total", "Sum of bets in EUR.", float64(stats.WageredEURTotal))
+	metrics.WriteCounter(w, "casino_materialized_won_eur_total", "Sum of wins in EUR.", float64(stats.WonEURTotal))
+	metrics.WriteGauge(w, "casino_materialized_ggr_eur", "Gross gaming revenue in EUR: wagered minus won.", float64(stats.GGREUR))
+	metrics.WriteGauge(w, "casino_materialized_rtp", "Observed return to player: won divided by wagered.", stats.RTP)
 }
 
 // StartHTTPServer starts the HTTP server to serve the materialized data.
 func (m *Materialize) StartHTTPServer() {
 	http.HandleFunc("/materialized", m.GetStats)
-	log.Info().Msg("Materialized data available at http://localhost:8080/materialized")
-	err := http.ListenAndServe(":8080", nil)
+	http.HandleFunc("/materialized/distributions", m.GetDistributionsHandler)
+	http.HandleFunc("/players/", m.ServePlayers)
+	http.HandleFunc("/metrics", metrics.Handler(m))
+
+	streamer := NewStreamer(m, m.options.StreamInterval)
+	go streamer.Run(context.Background())
+	http.HandleFunc("/materialized/stream", streamer.ServeSSE)
+	http.HandleFunc("/materialized/ws", streamer.ServeWebSocket)
+	log.Info().Msgf("Materialized data available at http://localhost%s/materialized", m.options.Addr)
+	err := http.ListenAndServe(m.options.Addr, nil)
 	if err != nil {
 		return
 	}
 }
 
+// ggr returns the gross gaming revenue and the observed return to player
+// for the given wagered and won amounts.
+func ggr(wagered, won int) (int, float64) {
+	if wagered == 0 {
+		return -won, 0
+	}
+	return wagered - won, float64(won) / float64(wagered)
+}
+
 // getTopPlayer finds the player with the highest count in a given map.
-func (m *Materialize) getTopPlayer(playerMap map[int]int) PlayerStats {
+// Ties go to the lowest player ID, so the result doesn't depend on map order.
+func getTopPlayer(playerMap map[int]int) PlayerStats {
 	var topPlayer PlayerStats
 	for id, count := range playerMap {
-		if count > topPlayer.Count {
+		if count > topPlayer.Count || (count == topPlayer.Count && count > 0 && id < topPlayer.ID) {
 			topPlayer.ID = id
 			topPlayer.Count = count
 		}
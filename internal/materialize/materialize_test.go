@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
@@ -41,6 +42,43 @@ func TestUpdateStats(t *testing.T) {
 	}
 }
 
+func TestUpdateStatsWins(t *testing.T) {
+	materializer := NewMaterialize()
+	now := time.Now()
+
+	events := []casino.Event{
+		{PlayerID: 1, Type: "bet", AmountEUR: 100, CreatedAt: now},
+		{PlayerID: 1, Type: "bet", AmountEUR: 100, HasWon: true, WinAmountEUR: 150, CreatedAt: now},
+		{PlayerID: 2, Type: "bet", AmountEUR: 200, HasWon: true, WinAmountEUR: 10, CreatedAt: now},
+		{PlayerID: 2, Type: "game_stop", HasWon: true, CreatedAt: now},
+	}
+	for _, event := range events {
+		materializer.UpdateStats(event)
+	}
+
+	stats := materializer.stats
+	if stats.WageredEURTotal != 400 || stats.WonEURTotal != 160 || stats.GGREUR != 240 {
+		t.Errorf("Expected wagered 400, won 160 and GGR 240, got %d, %d and %d", stats.WageredEURTotal, stats.WonEURTotal, stats.GGREUR)
+	}
+	if stats.RTP != 0.4 {
+		t.Errorf("Expected RTP to be 0.4, got %f", stats.RTP)
+	}
+	if stats.TopPlayerWinsEUR.ID != 1 || stats.TopPlayerWinsEUR.Count != 150 {
+		t.Errorf("Expected TopPlayerWinsEUR to be {ID: 1, Count: 150}, got %+v", stats.TopPlayerWinsEUR)
+	}
+
+	// Wins are counted from bets, not from game_stop
+	profile, _ := materializer.GetPlayer(2)
+	if profile.Wins != 1 || profile.WonEUR != 10 || profile.NetEUR != -190 {
+		t.Errorf("Expected 1 win of 10 EUR and net -190 EUR, got %+v", profile)
+	}
+
+	filtered := materializer.QueryStats(Query{PlayerID: &profile.ID})
+	if filtered.WonEURTotal != 10 || filtered.GGREUR != 190 {
+		t.Errorf("Expected filtered won 10 and GGR 190, got %d and %d", filtered.WonEURTotal, filtered.GGREUR)
+	}
+}
+
 func TestGetStats(t *testing.T) {
 	// Create an instance of Materializer
 	materializer := NewMaterialize()
@@ -82,3 +120,360 @@ func TestGetStats(t *testing.T) {
 		t.Errorf("Expected TopPlayerBets to be {ID: 1, Count: 1}, got %+v", response.TopPlayerBets)
 	}
 }
+
+func TestGetPlayerStats(t *testing.T) {
+	materializer := NewMaterialize()
+
+	now := time.Now()
+	events := []casino.Event{
+		{PlayerID: 7, Type: "deposit", Amount: 1000, AmountEUR: 1000, CreatedAt: now.Add(-2 * time.Hour)},
+		{PlayerID: 7, Type: "game_start", GameID: 101, CreatedAt: now.Add(-time.Minute)},
+		{PlayerID: 7, Type: "bet", GameID: 101, Amount: 300, AmountEUR: 300, CreatedAt: now},
+	}
+	for _, event := range events {
+		materializer.UpdateStats(event)
+	}
+
+	req, err := http.NewRequest("GET", "/players/7/stats", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(materializer.ServePlayers)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response PlayerProfile
+	err = json.NewDecoder(rr.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Bets != 1 || response.WageredEUR != 300 || response.DepositsEUR != 1000 || response.NetEUR != 700 {
+		t.Errorf("Unexpected lifetime metrics: %+v", response)
+	}
+
+	if response.LastHour.Bets != 1 || response.LastHour.DepositsEUR != 0 {
+		t.Errorf("Unexpected last hour metrics: %+v", response.LastHour)
+	}
+
+	if response.CurrentSession == nil || response.CurrentSession.GameID != 101 || response.CurrentSession.Bets != 1 {
+		t.Errorf("Expected current session on game 101 with 1 bet, got %+v", response.CurrentSession)
+	}
+
+	rr = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/players/8/stats", nil)
+	handler.ServeHTTP(rr, req)
+	if status := rr.Code; status != http.StatusNotFound {
+		t.Errorf("Handler returned wrong status code for unknown player: got %v want %v", status, http.StatusNotFound)
+	}
+}
+
+func TestPlayerWindowLateEvents(t *testing.T) {
+	materializer := NewMaterialize()
+
+	now := time.Now()
+	events := []casino.Event{
+		{PlayerID: 7, Type: "bet", AmountEUR: 300, CreatedAt: now},
+		// A whole window late, it must not reset the slot of the current minute.
+		{PlayerID: 7, Type: "bet", AmountEUR: 100, CreatedAt: now.Add(-windowBuckets * time.Minute)},
+		{PlayerID: 7, Type: "bet", AmountEUR: 100, CreatedAt: now.Add(-2 * windowBuckets * time.Minute)},
+	}
+	for _, event := range events {
+		materializer.UpdateStats(event)
+	}
+
+	profile, ok := materializer.GetPlayer(7)
+	if !ok || profile.Bets != 3 || profile.LastHour.Bets != 1 || profile.LastHour.WageredEUR != 300 {
+		t.Errorf("Expected the late bets to count only in the lifetime metrics, got %+v", profile)
+	}
+}
+
+func TestUpdateStatsApprox(t *testing.T) {
+	options := DefaultOptions()
+	options.TopPlayerBets = ModeApprox
+	options.UniquePlayers = ModeApprox
+	options.HeavyHittersError = 0.1
+	materializer := NewMaterializeWithOptions(options)
+
+	now := time.Now()
+	for i := 0; i < 1000; i++ {
+		materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "bet", CreatedAt: now})
+		materializer.UpdateStats(casino.Event{PlayerID: 100 + i, Type: "bet", CreatedAt: now})
+	}
+
+	stats, _ := materializer.snapshot()
+
+	if stats.TopPlayerBets.ID != 1 || !stats.TopPlayerBets.Approximate {
+		t.Errorf("Expected approximate TopPlayerBets for player 1, got %+v", stats.TopPlayerBets)
+	}
+
+	if stats.UniquePlayersTotal < 970 || stats.UniquePlayersTotal > 1032 {
+		t.Errorf("Expected UniquePlayersTotal to be about 1001, got %d", stats.UniquePlayersTotal)
+	}
+
+	if stats.UniquePlayersLastHour != stats.UniquePlayersTotal {
+		t.Errorf("Expected UniquePlayersLastHour %d to equal UniquePlayersTotal %d", stats.UniquePlayersLastHour, stats.UniquePlayersTotal)
+	}
+}
+
+func TestUpdateStatsApproxTopPlayer(t *testing.T) {
+	options := DefaultOptions()
+	options.TopPlayerBets = ModeApprox
+	options.HeavyHittersError = 0.25
+	materializer := NewMaterializeWithOptions(options)
+
+	now := time.Now()
+	for i, id := range []int{5, 3, 3, 5, 1, 2, 4, 6, 7, 8, 9, 5} {
+		materializer.UpdateStats(casino.Event{PlayerID: id, Type: "bet", CreatedAt: now})
+
+		stats, _ := materializer.snapshot()
+		top := materializer.playerBets.approx.Top(1)[0]
+		if stats.TopPlayerBets.ID != top.Item || stats.TopPlayerBets.Count != top.Count {
+			t.Fatalf("Expected TopPlayerBets %+v after event %d, got %+v", top, i, stats.TopPlayerBets)
+		}
+	}
+}
+
+func TestUniquePlayersLateEvents(t *testing.T) {
+	for _, mode := range []Mode{ModeExact, ModeApprox} {
+		options := DefaultOptions()
+		options.UniquePlayers = mode
+		materializer := NewMaterializeWithOptions(options)
+
+		now := time.Now()
+		events := []casino.Event{
+			// Before 1970 the bucket index must not be negative.
+			{PlayerID: 7, Type: "bet", CreatedAt: time.Unix(-90, 0)},
+			{PlayerID: 8, Type: "bet", CreatedAt: now},
+			// A whole window late, it must not reset the slot of the current minute.
+			{PlayerID: 9, Type: "bet", CreatedAt: now.Add(-windowBuckets * time.Minute)},
+		}
+		for _, event := range events {
+			materializer.UpdateStats(event)
+		}
+
+		stats, _ := materializer.snapshot()
+		if stats.UniquePlayersTotal != 3 || stats.UniquePlayersLastHour != 1 {
+			t.Errorf("%s: expected 3 unique players in total and 1 in the last hour, got %d and %d", mode, stats.UniquePlayersTotal, stats.UniquePlayersLastHour)
+		}
+	}
+}
+
+func TestUpdateStatsApproxBoundsPlayers(t *testing.T) {
+	options := DefaultOptions()
+	options.UniquePlayers = ModeApprox
+	options.MaxPlayerProfiles = 2
+	materializer := NewMaterializeWithOptions(options)
+
+	now := time.Now()
+	for _, id := range []int{1, 2, 1, 3} {
+		materializer.UpdateStats(casino.Event{PlayerID: id, Type: "bet", AmountEUR: 10, CreatedAt: now})
+	}
+
+	if len(materializer.players) != 2 {
+		t.Errorf("Expected 2 player profiles, got %d", len(materializer.players))
+	}
+	if _, ok := materializer.GetPlayer(2); ok {
+		t.Errorf("Expected least recently active player 2 to be evicted")
+	}
+	if profile, ok := materializer.GetPlayer(1); !ok || profile.Bets != 2 {
+		t.Errorf("Expected player 1 with 2 bets, got %+v", profile)
+	}
+}
+
+func TestGetDistributions(t *testing.T) {
+	materializer := NewMaterialize()
+
+	for i := 1; i <= 100; i++ {
+		materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "bet", GameID: 100, Currency: "EUR", AmountEUR: i, CreatedAt: time.Now()})
+	}
+	materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "deposit", Currency: "USD", AmountEUR: 5000, CreatedAt: time.Now()})
+
+	req, err := http.NewRequest("GET", "/materialized/distributions", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	handler := http.HandlerFunc(materializer.GetDistributionsHandler)
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Fatalf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	var response Distributions
+	err = json.NewDecoder(rr.Body).Decode(&response)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bets := response.Bet.Global
+	if bets.Count != 100 || bets.P50 != 50 || bets.P90 != 90 || bets.P99 != 99 || bets.Max != 100 {
+		t.Errorf("Unexpected bet distribution: %+v", bets)
+	}
+
+	if response.Bet.ByGame["100"].Count != 100 || response.Bet.ByCurrency["EUR"].Count != 100 {
+		t.Errorf("Expected 100 bets for game 100 and EUR, got %+v", response.Bet)
+	}
+
+	if response.Deposit.ByCurrency["USD"].Max != 5000 || response.Deposit.ByGame != nil {
+		t.Errorf("Unexpected deposit distributions: %+v", response.Deposit)
+	}
+}
+
+func TestGetStatsQuery(t *testing.T) {
+	materializer := NewMaterialize()
+
+	now := time.Now()
+	events := []casino.Event{
+		{PlayerID: 1, Type: "bet", GameID: 100, Currency: "EUR", AmountEUR: 10, CreatedAt: now},
+		{PlayerID: 2, Type: "bet", GameID: 101, Currency: "USD", AmountEUR: 20, CreatedAt: now},
+		{PlayerID: 2, Type: "bet", GameID: 101, Currency: "USD", AmountEUR: 20, CreatedAt: now},
+		{PlayerID: 3, Type: "deposit", Currency: "USD", AmountEUR: 500, CreatedAt: now},
+	}
+	for _, event := range events {
+		materializer.UpdateStats(event)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		status   int
+		errors   int
+		expected map[string]interface{}
+	}{
+		{
+			name:     "filter by game",
+			query:    "?game_id=100",
+			status:   http.StatusOK,
+			expected: map[string]interface{}{"events_total": 1.0},
+		},
+		{
+			name:     "filter by type and currency with field selection",
+			query:    "?type=bet&currency=usd&fields=events_total,top_player_bets",
+			status:   http.StatusOK,
+			expected: map[string]interface{}{"events_total": 2.0, "top_player_bets": map[string]interface{}{"id": 2.0, "count": 2.0}},
+		},
+		{
+			name:     "filter by player",
+			query:    "?player_id=3&fields=top_player_deposits",
+			status:   http.StatusOK,
+			expected: map[string]interface{}{"top_player_deposits": map[string]interface{}{"id": 3.0, "count": 500.0}},
+		},
+		{
+			name:   "invalid parameters",
+			query:  "?game_id=abc&type=spin&fields=nope&from=yesterday&foo=bar",
+			status: http.StatusBadRequest,
+			errors: 5,
+		},
+		{
+			name:   "from outside retention",
+			query:  "?type=bet&from=" + now.Add(-2*time.Hour).UTC().Format(time.RFC3339),
+			status: http.StatusBadRequest,
+			errors: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/materialized"+tt.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rr := httptest.NewRecorder()
+			http.HandlerFunc(materializer.GetStats).ServeHTTP(rr, req)
+
+			if status := rr.Code; status != tt.status {
+				t.Fatalf("Handler returned wrong status code: got %v want %v", status, tt.status)
+			}
+
+			if tt.status == http.StatusBadRequest {
+				var response struct {
+					Errors []QueryError `json:"errors"`
+				}
+				if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+					t.Fatal(err)
+				}
+				if len(response.Errors) != tt.errors {
+					t.Errorf("Expected %d errors, got %+v", tt.errors, response.Errors)
+				}
+				return
+			}
+
+			var response map[string]interface{}
+			if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range tt.expected {
+				got, _ := json.Marshal(response[k])
+				want, _ := json.Marshal(v)
+				if string(got) != string(want) {
+					t.Errorf("Expected %s to be %s, got %s", k, want, got)
+				}
+			}
+			if strings.Contains(tt.query, "fields=") && len(response) != len(tt.expected) {
+				t.Errorf("Expected only selected fields, got %v", response)
+			}
+		})
+	}
+}
+
+func TestQueryStatsPartial(t *testing.T) {
+	materializer := NewMaterialize()
+
+	now := time.Now()
+	materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "bet", AmountEUR: 10, CreatedAt: now})
+	materializer.recentTruncated = now.Add(-10 * time.Minute)
+
+	bet := []string{"bet"}
+	if stats := materializer.QueryStats(Query{Types: bet}); !stats.Partial {
+		t.Errorf("Expected stats of the last hour to be partial, got %+v", stats)
+	}
+	if stats := materializer.QueryStats(Query{Types: bet, From: now.Add(-5 * time.Minute)}); stats.Partial || stats.EventsTotal != 1 {
+		t.Errorf("Expected complete stats after the dropped events, got %+v", stats)
+	}
+	if stats := materializer.QueryStats(Query{Types: bet, From: now.Add(-2 * time.Hour)}); !stats.Partial {
+		t.Errorf("Expected stats from before retention to be partial, got %+v", stats)
+	}
+}
+
+func TestGetStatsETag(t *testing.T) {
+	materializer := NewMaterialize()
+	materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "bet", CreatedAt: time.Now()})
+
+	handler := http.HandlerFunc(materializer.GetStats)
+
+	req, _ := http.NewRequest("GET", "/materialized", nil)
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	etag := rr.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("Expected ETag header")
+	}
+
+	req, _ = http.NewRequest("GET", "/materialized", nil)
+	req.Header.Set("If-None-Match", etag)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusNotModified {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusNotModified)
+	}
+
+	materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "bet", CreatedAt: time.Now()})
+
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code after update: got %v want %v", status, http.StatusOK)
+	}
+}
@@ -0,0 +1,259 @@
+package materialize
+
+import (
+	"container/list"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// windowBuckets is the number of one-minute buckets kept per player,
+// which bounds the windowed metrics to the last hour.
+const windowBuckets = 60
+
+// minuteOf returns the minutes since the Unix epoch at t, rounded down, so
+// times before 1970 get their own negative minutes too.
+func minuteOf(t time.Time) int64 {
+	seconds := t.Unix()
+	if seconds < 0 && seconds%60 != 0 {
+		return seconds/60 - 1
+	}
+	return seconds / 60
+}
+
+// bucketIndex returns the ring slot of a minute, which is never negative.
+func bucketIndex(minute int64) int {
+	return int((minute%windowBuckets + windowBuckets) % windowBuckets)
+}
+
+// PlayerProfile represents the lifetime and windowed statistics of a player.
+type PlayerProfile struct {
+	ID             int            `json:"id"`
+	Bets           int            `json:"bets"`
+	WageredEUR     int            `json:"wagered_eur"`
+	DepositsEUR    int            `json:"deposits_eur"`
+	Wins           int            `json:"wins"`
+	WonEUR         int            `json:"won_eur"`
+	NetEUR         int            `json:"net_eur"`
+	GamesPlayed    int            `json:"games_played"`
+	FirstSeen      time.Time      `json:"first_seen"`
+	LastSeen       time.Time      `json:"last_seen"`
+	CurrentSession *PlayerSession `json:"current_session"`
+	LastHour       PlayerWindow   `json:"last_hour"`
+}
+
+// PlayerSession represents the game a player is currently playing.
+type PlayerSession struct {
+	GameID    int       `json:"game_id"`
+	StartedAt time.Time `json:"started_at"`
+	Bets      int       `json:"bets"`
+}
+
+// PlayerWindow represents player activity within a time window.
+type PlayerWindow struct {
+	Bets        int `json:"bets"`
+	WageredEUR  int `json:"wagered_eur"`
+	DepositsEUR int `json:"deposits_eur"`
+	Wins        int `json:"wins"`
+	WonEUR      int `json:"won_eur"`
+}
+
+// playerBucket accumulates player activity within a single minute.
+type playerBucket struct {
+	Minute int64 `json:"minute"`
+	PlayerWindow
+}
+
+// playerState holds the incrementally maintained state of a single player.
+type playerState struct {
+	profile PlayerProfile
+	buckets [windowBuckets]playerBucket
+
+	// recency is the element of the player in Materialize.playerOrder,
+	// if the number of players is bounded.
+	recency *list.Element
+}
+
+// apply updates the player state with the given event.
+func (p *playerState) apply(event casino.Event) {
+	if p.profile.FirstSeen.IsZero() || event.CreatedAt.Before(p.profile.FirstSeen) {
+		p.profile.FirstSeen = event.CreatedAt
+	}
+	if event.CreatedAt.After(p.profile.LastSeen) {
+		p.profile.LastSeen = event.CreatedAt
+	}
+
+	bucket := p.bucket(event.CreatedAt)
+
+	switch event.Type {
+	case "game_start":
+		p.profile.GamesPlayed++
+		p.profile.CurrentSession = &PlayerSession{GameID: event.GameID, StartedAt: event.CreatedAt}
+	case "bet":
+		p.profile.Bets++
+		p.profile.WageredEUR += event.AmountEUR
+		bucket.Bets++
+		bucket.WageredEUR += event.AmountEUR
+		if p.profile.CurrentSession != nil {
+			p.profile.CurrentSession.Bets++
+		}
+		if event.HasWon {
+			p.profile.Wins++
+			p.profile.WonEUR += event.WinAmountEUR
+			bucket.Wins++
+			bucket.WonEUR += event.WinAmountEUR
+		}
+	case "game_stop":
+		p.profile.CurrentSession = nil
+	case "deposit":
+		p.profile.DepositsEUR += event.AmountEUR
+		bucket.DepositsEUR += event.AmountEUR
+	}
+
+	p.profile.NetEUR = p.profile.DepositsEUR - p.profile.WageredEUR + p.profile.WonEUR
+}
+
+// bucket returns the minute bucket for the given time, resetting it if it
+// still holds data from an older minute. Times a whole window or more before
+// the last seen event get a scratch bucket, so they don't reset the slot of
+// a current minute.
+func (p *playerState) bucket(t time.Time) *playerBucket {
+	minute := minuteOf(t)
+	if minute <= minuteOf(p.profile.LastSeen)-windowBuckets {
+		return &playerBucket{Minute: minute}
+	}
+	b := &p.buckets[bucketIndex(minute)]
+	if b.Minute != minute {
+		*b = playerBucket{Minute: minute}
+	}
+	return b
+}
+
+// copyProfile returns a deep copy of the player profile.
+func (p *playerState) copyProfile() PlayerProfile {
+	profile := p.profile
+	if p.profile.CurrentSession != nil {
+		session := *p.profile.CurrentSession
+		profile.CurrentSession = &session
+	}
+	return profile
+}
+
+// snapshot returns a copy of the player profile with the windowed metrics
+// calculated relative to now.
+func (p *playerState) snapshot(now time.Time) PlayerProfile {
+	profile := p.copyProfile()
+
+	current := minuteOf(now)
+	for _, b := range p.buckets {
+		if b.Minute > current-windowBuckets && b.Minute <= current {
+			profile.LastHour.Bets += b.Bets
+			profile.LastHour.WageredEUR += b.WageredEUR
+			profile.LastHour.DepositsEUR += b.DepositsEUR
+			profile.LastHour.Wins += b.Wins
+			profile.LastHour.WonEUR += b.WonEUR
+		}
+	}
+	return profile
+}
+
+// player returns the state of the given player, creating it if needed. If the
+// number of players is bounded, the player becomes the most recently active
+// one and the least recently active players are evicted. It must be called
+// with m.mu held.
+func (m *Materialize) player(id int) *playerState {
+	state, ok := m.players[id]
+	if !ok {
+		state = &playerState{profile: PlayerProfile{ID: id}}
+		m.players[id] = state
+	}
+	if m.playerOrder == nil {
+		return state
+	}
+
+	if state.recency == nil {
+		state.recency = m.playerOrder.PushFront(id)
+	} else {
+		m.playerOrder.MoveToFront(state.recency)
+	}
+	for m.playerOrder.Len() > m.options.MaxPlayerProfiles {
+		oldest := m.playerOrder.Back()
+		delete(m.players, m.playerOrder.Remove(oldest).(int))
+	}
+	return state
+}
+
+// newPlayerOrder returns the list tracking player recency, or nil if the
+// number of players isn't bounded by the options.
+func newPlayerOrder(options Options) *list.List {
+	if !options.approximate() || options.MaxPlayerProfiles <= 0 {
+		return nil
+	}
+	return list.New()
+}
+
+// GetPlayer returns the profile of the given player, if the player has been seen.
+func (m *Materialize) GetPlayer(id int) (PlayerProfile, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.players[id]
+	if !ok {
+		return PlayerProfile{}, false
+	}
+	return state.snapshot(time.Now()), true
+}
+
+// PlayerHandler serves a resource of a single player.
+type PlayerHandler func(w http.ResponseWriter, r *http.Request, playerID int)
+
+// HandlePlayerResource registers a handler for paths of the form
+// /players/{id}/{resource}, next to the built-in stats resource.
+func (m *Materialize) HandlePlayerResource(resource string, handler PlayerHandler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.playerResources[resource] = handler
+}
+
+// ServePlayers handles HTTP requests for paths of the form /players/{id}/{resource}.
+func (m *Materialize) ServePlayers(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 || parts[0] != "players" {
+		http.NotFound(w, r)
+		return
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		http.Error(w, "invalid player id", http.StatusBadRequest)
+		return
+	}
+
+	m.mu.Lock()
+	handler, ok := m.playerResources[parts[2]]
+	m.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	handler(w, r, id)
+}
+
+// servePlayerStats writes the profile of the given player.
+func (m *Materialize) servePlayerStats(w http.ResponseWriter, r *http.Request, id int) {
+	profile, ok := m.GetPlayer(id)
+	if !ok {
+		http.Error(w, "player not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	err := json.NewEncoder(w).Encode(profile)
+	if err != nil {
+		return
+	}
+}
@@ -0,0 +1,379 @@
+package materialize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// Retention of the events used to answer filtered queries.
+const (
+	recentRetention = time.Hour
+	recentMaxEvents = 100000
+)
+
+// recentEvent is the subset of an event needed to answer filtered queries.
+type recentEvent struct {
+	PlayerID     int       `json:"player_id"`
+	GameID       int       `json:"game_id,omitempty"`
+	Type         string    `json:"type"`
+	Currency     string    `json:"currency,omitempty"`
+	AmountEUR    int       `json:"amount_eur,omitempty"`
+	HasWon       bool      `json:"has_won,omitempty"`
+	WinAmountEUR int       `json:"win_amount_eur,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Query represents the filters and field selection of a stats request.
+type Query struct {
+	Types    []string
+	GameID   *int
+	Currency string
+	PlayerID *int
+	From     time.Time
+	To       time.Time
+	Fields   []string
+}
+
+// IsFiltered reports whether the query filters events.
+func (q Query) IsFiltered() bool {
+	return len(q.Types) > 0 || q.GameID != nil || q.Currency != "" || q.PlayerID != nil || !q.From.IsZero() || !q.To.IsZero()
+}
+
+// QueryError describes an invalid query parameter.
+type QueryError struct {
+	Param   string `json:"param"`
+	Message string `json:"message"`
+}
+
+// ParseQuery parses and validates the query parameters of a stats request.
+func ParseQuery(values url.Values) (Query, []QueryError) {
+	var q Query
+	var errs []QueryError
+
+	known := map[string]bool{"type": true, "game_id": true, "currency": true, "player_id": true, "from": true, "to": true, "fields": true}
+	for param := range values {
+		if !known[param] {
+			errs = append(errs, QueryError{Param: param, Message: "unknown parameter"})
+		}
+	}
+
+	if v := values.Get("type"); v != "" {
+		for _, t := range strings.Split(v, ",") {
+			if !isEventType(t) {
+				errs = append(errs, QueryError{Param: "type", Message: fmt.Sprintf("unknown event type %q", t)})
+				continue
+			}
+			q.Types = append(q.Types, t)
+		}
+	}
+
+	q.GameID, errs = parseIntParam(values, "game_id", errs)
+	q.PlayerID, errs = parseIntParam(values, "player_id", errs)
+
+	if v := values.Get("currency"); v != "" {
+		q.Currency = strings.ToUpper(v)
+		if !isCurrency(q.Currency) {
+			errs = append(errs, QueryError{Param: "currency", Message: fmt.Sprintf("unknown currency %q", v)})
+		}
+	}
+
+	q.From, errs = parseTimeParam(values, "from", errs)
+	q.To, errs = parseTimeParam(values, "to", errs)
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		errs = append(errs, QueryError{Param: "to", Message: "must not be before from"})
+	}
+
+	if v := values.Get("fields"); v != "" {
+		fields, _ := fieldsOf(Stats{})
+		for _, f := range strings.Split(v, ",") {
+			if _, ok := fields[f]; !ok {
+				errs = append(errs, QueryError{Param: "fields", Message: fmt.Sprintf("unknown field %q", f)})
+				continue
+			}
+			q.Fields = append(q.Fields, f)
+		}
+	}
+
+	return q, errs
+}
+
+// matches reports whether the event passes the query filters.
+func (q Query) matches(e recentEvent) bool {
+	if len(q.Types) > 0 {
+		found := false
+		for _, t := range q.Types {
+			if e.Type == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.GameID != nil && e.GameID != *q.GameID {
+		return false
+	}
+	if q.Currency != "" && e.Currency != q.Currency {
+		return false
+	}
+	if q.PlayerID != nil && e.PlayerID != *q.PlayerID {
+		return false
+	}
+	if !q.From.IsZero() && e.CreatedAt.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !e.CreatedAt.Before(q.To) {
+		return false
+	}
+	return true
+}
+
+// checkRetention reports the time filters that reach further back than the
+// events kept for filtered queries.
+func (q Query) checkRetention(now time.Time) []QueryError {
+	var errs []QueryError
+	cutoff := now.Add(-recentRetention)
+	if !q.From.IsZero() && q.From.Before(cutoff) {
+		errs = append(errs, QueryError{Param: "from", Message: fmt.Sprintf("must be within the last %s", recentRetention)})
+	}
+	if !q.To.IsZero() && q.To.Before(cutoff) {
+		errs = append(errs, QueryError{Param: "to", Message: fmt.Sprintf("must be within the last %s", recentRetention)})
+	}
+	return errs
+}
+
+// recordRecent keeps the event for filtered queries and drops events past retention.
+// Events dropped because of the size cap are remembered, so queries covering
+// them can be flagged as partial. It must be called with m.mu held.
+func (m *Materialize) recordRecent(event casino.Event, now time.Time) {
+	m.recent = append(m.recent, recentEvent{
+		PlayerID:     event.PlayerID,
+		GameID:       event.GameID,
+		Type:         event.Type,
+		Currency:     event.Currency,
+		AmountEUR:    event.AmountEUR,
+		HasWon:       event.HasWon,
+		WinAmountEUR: event.WinAmountEUR,
+		CreatedAt:    event.CreatedAt,
+	})
+
+	cutoff := now.Add(-recentRetention)
+	for len(m.recent) > 0 && (len(m.recent) > recentMaxEvents || m.recent[0].CreatedAt.Before(cutoff)) {
+		if dropped := m.recent[0].CreatedAt; !dropped.Before(cutoff) && dropped.After(m.recentTruncated) {
+			m.recentTruncated = dropped
+		}
+		m.recent = m.recent[1:]
+	}
+}
+
+// QueryStats returns the stats for the given query. Unfiltered queries return
+// the incrementally maintained stats; filtered ones are computed exactly from
+// the events of the last hour, and marked as partial if events within the
+// queried range were dropped or lie outside the last hour.
+func (m *Materialize) QueryStats(q Query) Stats {
+	m.mu.Lock()
+	if !q.IsFiltered() {
+		defer m.mu.Unlock()
+		return m.currentStats()
+	}
+	recent := append([]recentEvent(nil), m.recent...)
+	truncated := m.recentTruncated
+	m.mu.Unlock()
+
+	now := time.Now()
+	oneMinuteAgo := now.Add(-time.Minute)
+	bets := make(map[int]int)
+	wins := make(map[int]int)
+	winsEUR := make(map[int]int)
+	deposits := make(map[int]int)
+	players := make(map[int]struct{})
+	var stats Stats
+	var lastMinute int
+
+	for _, e := range recent {
+		if !q.matches(e) {
+			continue
+		}
+		stats.EventsTotal++
+		if !e.CreatedAt.Before(oneMinuteAgo) {
+			lastMinute++
+		}
+		players[e.PlayerID] = struct{}{}
+
+		switch e.Type {
+		case "bet":
+			bets[e.PlayerID]++
+			stats.WageredEURTotal += e.AmountEUR
+			if e.HasWon {
+				wins[e.PlayerID]++
+				winsEUR[e.PlayerID] += e.WinAmountEUR
+				stats.WonEURTotal += e.WinAmountEUR
+			}
+		case "deposit":
+			deposits[e.PlayerID] += e.AmountEUR
+		}
+	}
+
+	stats.EventsPerMinute = float64(lastMinute)
+	stats.EventsPerSecondMovingAvg = float64(lastMinute) / 60.0
+	stats.TopPlayerBets = getTopPlayer(bets)
+	stats.TopPlayerWins = getTopPlayer(wins)
+	stats.TopPlayerWinsEUR = getTopPlayer(winsEUR)
+	stats.GGREUR, stats.RTP = ggr(stats.WageredEURTotal, stats.WonEURTotal)
+	stats.TopPlayerDeposits = getTopPlayer(deposits)
+	stats.UniquePlayersTotal = len(players)
+	stats.UniquePlayersLastHour = len(players)
+
+	from := now.Add(-recentRetention)
+	if !q.From.IsZero() {
+		if q.From.Before(from) {
+			stats.Partial = true
+		}
+		from = q.From
+	}
+	if !truncated.IsZero() && !truncated.Before(from) && (q.To.IsZero() || truncated.Before(q.To)) {
+		stats.Partial = true
+	}
+	return stats
+}
+
+// GetStats handles HTTP requests to retrieve the materialized data.
+// It supports filtering by type, game_id, currency, player_id, from and to,
+// selecting fields with fields, and conditional requests with If-None-Match.
+func (m *Materialize) GetStats(w http.ResponseWriter, r *http.Request) {
+	q, errs := ParseQuery(r.URL.Query())
+	if len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(struct {
+			Errors []QueryError `json:"errors"`
+		}{errs})
+		return
+	}
+
+	if errs := q.checkRetention(time.Now()); len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(struct {
+			Errors []QueryError `json:"errors"`
+		}{errs})
+		return
+	}
+
+	stats := m.QueryStats(q)
+
+	var body []byte
+	var err error
+	if len(q.Fields) > 0 {
+		all, _ := fieldsOf(stats)
+		selected := make(map[string]json.RawMessage, len(q.Fields))
+		for _, f := range q.Fields {
+			selected[f] = all[f]
+		}
+		if stats.Partial {
+			selected["partial"] = all["partial"]
+		}
+		body, err = json.Marshal(selected)
+	} else {
+		body, err = json.Marshal(stats)
+	}
+	if err != nil {
+		http.Error(w, "failed to encode stats", http.StatusInternalServerError)
+		return
+	}
+	body = append(body, '\n')
+
+	etag := etagOf(body)
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Cache-Control", "no-cache")
+	if matchesETag(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(body)
+	if err != nil {
+		return
+	}
+}
+
+func parseIntParam(values url.Values, param string, errs []QueryError) (*int, []QueryError) {
+	v := values.Get(param)
+	if v == "" {
+		return nil, errs
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return nil, append(errs, QueryError{Param: param, Message: "must be an integer"})
+	}
+	return &n, errs
+}
+
+func parseTimeParam(values url.Values, param string, errs []QueryError) (time.Time, []QueryError) {
+	v := values.Get(param)
+	if v == "" {
+		return time.Time{}, errs
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return time.Time{}, append(errs, QueryError{Param: param, Message: "must be an RFC 3339 timestamp"})
+	}
+	return t, errs
+}
+
+func isEventType(t string) bool {
+	for _, et := range casino.EventTypes {
+		if t == et {
+			return true
+		}
+	}
+	return false
+}
+
+func isCurrency(c string) bool {
+	for _, cur := range casino.Currencies {
+		if c == cur {
+			return true
+		}
+	}
+	return false
+}
+
+func etagOf(body []byte) string {
+	h := fnv.New64a()
+	_, _ = h.Write(body)
+	return fmt.Sprintf("\"%x\"", h.Sum64())
+}
+
+// matchesETag reports whether an If-None-Match header matches the ETag.
+func matchesETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldsOf returns the JSON encoding of each top-level field of v.
+func fieldsOf(v interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var result map[string]json.RawMessage
+	err = json.NewDecoder(bytes.NewReader(data)).Decode(&result)
+	return result, err
+}
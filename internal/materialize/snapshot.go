@@ -0,0 +1,382 @@
+package materialize
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/sketch"
+	_ "github.com/lib/pq" // PostgreSQL driver
+	"github.com/rs/zerolog/log"
+)
+
+// SnapshotVersion is the version of the snapshot format written by this build.
+// Bump it whenever materializeState changes and migrate older versions in Restore
+// where possible.
+//
+//  1. Exact counters, player profiles and the last event ID.
+//  2. Sketches for approximate top players and unique players.
+//  3. Bet and deposit amount histograms.
+//  4. Recent events for filtered queries.
+//  5. Top players by sum of wins in EUR, and wins counted on bets instead of game_stop.
+//     The last event ID is no longer stored.
+//  6. Time of the newest recent event dropped because of the size cap. Version 5
+//     snapshots are migrated without it.
+//  7. The last event ID is stored again. Version 5 and 6 snapshots are migrated
+//     with 0.
+const SnapshotVersion = 7
+
+// minSnapshotVersion is the oldest snapshot version Restore can still load.
+// Older snapshots count wins differently and can't be migrated.
+const minSnapshotVersion = 5
+
+// ErrNoSnapshot is returned by a SnapshotStore when there is nothing to restore.
+var ErrNoSnapshot = errors.New("no snapshot found")
+
+// ErrSnapshotLocked is returned by Lock when another writer holds the snapshot.
+var ErrSnapshotLocked = errors.New("snapshot is locked by another writer")
+
+// Snapshot is the versioned, serialized state of a Materialize.
+type Snapshot struct {
+	Version int              `json:"version"`
+	TakenAt time.Time        `json:"taken_at"`
+	State   materializeState `json:"state"`
+}
+
+// materializeState holds everything needed to rebuild a Materialize.
+type materializeState struct {
+	Stats                Stats                       `json:"stats"`
+	LastEventID          int                         `json:"last_event_id,omitempty"`
+	EventTimestamps      []time.Time                 `json:"event_timestamps"`
+	PlayerBets           map[int]int                 `json:"player_bets,omitempty"`
+	PlayerWins           map[int]int                 `json:"player_wins,omitempty"`
+	PlayerWinsEUR        map[int]int                 `json:"player_wins_eur,omitempty"`
+	PlayerDeposits       map[int]int                 `json:"player_deposits,omitempty"`
+	PlayerBetsSketch     *sketch.SpaceSaving         `json:"player_bets_sketch,omitempty"`
+	PlayerWinsSketch     *sketch.SpaceSaving         `json:"player_wins_sketch,omitempty"`
+	PlayerWinsEURSketch  *sketch.SpaceSaving         `json:"player_wins_eur_sketch,omitempty"`
+	PlayerDepositsSketch *sketch.SpaceSaving         `json:"player_deposits_sketch,omitempty"`
+	UniquePlayers        *uniqueTracker              `json:"unique_players,omitempty"`
+	BetAmounts           *amountHistograms           `json:"bet_amounts,omitempty"`
+	DepositAmounts       *amountHistograms           `json:"deposit_amounts,omitempty"`
+	Recent               []recentEvent               `json:"recent,omitempty"`
+	RecentTruncated      time.Time                   `json:"recent_truncated,omitempty"`
+	Players              map[int]playerStateSnapshot `json:"players"`
+}
+
+// playerStateSnapshot is the serialized form of playerState.
+type playerStateSnapshot struct {
+	Profile PlayerProfile               `json:"profile"`
+	Buckets [windowBuckets]playerBucket `json:"buckets"`
+}
+
+// SnapshotStore persists and loads snapshots.
+type SnapshotStore interface {
+	Save(data []byte) error
+	Load() ([]byte, error)
+}
+
+// Snapshot returns the current state of the materializer.
+func (m *Materialize) Snapshot() Snapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state := materializeState{
+		Stats:                m.stats,
+		LastEventID:          m.lastEventID,
+		EventTimestamps:      append([]time.Time(nil), m.eventTimestamps...),
+		PlayerBets:           copyCounts(m.playerBets.exact),
+		PlayerWins:           copyCounts(m.playerWins.exact),
+		PlayerWinsEUR:        copyCounts(m.playerWinsEUR.exact),
+		PlayerDeposits:       copyCounts(m.playerDeposits.exact),
+		PlayerBetsSketch:     copySketch(m.playerBets.approx),
+		PlayerWinsSketch:     copySketch(m.playerWins.approx),
+		PlayerWinsEURSketch:  copySketch(m.playerWinsEUR.approx),
+		PlayerDepositsSketch: copySketch(m.playerDeposits.approx),
+		UniquePlayers:        m.uniquePlayers.clone(),
+		BetAmounts:           m.betAmounts.clone(),
+		DepositAmounts:       m.depositAmounts.clone(),
+		Recent:               append([]recentEvent(nil), m.recent...),
+		RecentTruncated:      m.recentTruncated,
+		Players:              make(map[int]playerStateSnapshot, len(m.players)),
+	}
+	for id, p := range m.players {
+		state.Players[id] = playerStateSnapshot{Profile: p.copyProfile(), Buckets: p.buckets}
+	}
+
+	return Snapshot{Version: SnapshotVersion, TakenAt: time.Now(), State: state}
+}
+
+// Restore replaces the state of the materializer with the given snapshot.
+func (m *Materialize) Restore(snapshot Snapshot) error {
+	if snapshot.Version > SnapshotVersion {
+		return fmt.Errorf("snapshot version %d is newer than supported version %d", snapshot.Version, SnapshotVersion)
+	}
+	if snapshot.Version < minSnapshotVersion {
+		return fmt.Errorf("snapshot version %d is older than supported version %d", snapshot.Version, minSnapshotVersion)
+	}
+
+	state := snapshot.State
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.stats = state.Stats
+	m.lastEventID = state.LastEventID
+	m.eventTimestamps = state.EventTimestamps
+	o := m.options
+	m.playerBets = restorePlayerCounter(o.TopPlayerBets, o.HeavyHittersError, state.PlayerBets, state.PlayerBetsSketch)
+	m.playerWins = restorePlayerCounter(o.TopPlayerWins, o.HeavyHittersError, state.PlayerWins, state.PlayerWinsSketch)
+	m.playerWinsEUR = restorePlayerCounter(o.TopPlayerWins, o.HeavyHittersError, state.PlayerWinsEUR, state.PlayerWinsEURSketch)
+	m.playerDeposits = restorePlayerCounter(o.TopPlayerDeposits, o.HeavyHittersError, state.PlayerDeposits, state.PlayerDepositsSketch)
+	m.uniquePlayers = newUniqueTracker(o.UniquePlayers, o.UniquePlayersError)
+	if u := state.UniquePlayers; u != nil && u.Mode == o.UniquePlayers && u.StdErr == o.UniquePlayersError {
+		m.uniquePlayers = u
+	} else {
+		log.Warn().Msg("Unique player counts in snapshot don't match the configured mode, starting from zero")
+	}
+	m.betAmounts = newAmountHistograms()
+	if state.BetAmounts != nil {
+		m.betAmounts = state.BetAmounts
+	}
+	m.depositAmounts = newAmountHistograms()
+	if state.DepositAmounts != nil {
+		m.depositAmounts = state.DepositAmounts
+	}
+	m.recent = state.Recent
+	m.recentTruncated = state.RecentTruncated
+	m.players = make(map[int]*playerState, len(state.Players))
+	m.playerOrder = newPlayerOrder(o)
+	ids := make([]int, 0, len(state.Players))
+	for id := range state.Players {
+		ids = append(ids, id)
+	}
+	// Restore the players from least to most recently seen, so the bound
+	// keeps the most recent ones.
+	sort.Slice(ids, func(i, j int) bool {
+		return state.Players[ids[i]].Profile.LastSeen.Before(state.Players[ids[j]].Profile.LastSeen)
+	})
+	for _, id := range ids {
+		p := state.Players[id]
+		player := m.player(id)
+		player.profile, player.buckets = p.Profile, p.Buckets
+	}
+	m.version++
+
+	return nil
+}
+
+// SaveSnapshot writes the current state to the given store.
+func (m *Materialize) SaveSnapshot(store SnapshotStore) error {
+	data, err := json.Marshal(m.Snapshot())
+	if err != nil {
+		return fmt.Errorf("error encoding snapshot: %w", err)
+	}
+	return store.Save(data)
+}
+
+// RestoreSnapshot loads the state from the given store. It returns
+// ErrNoSnapshot if the store is empty.
+func (m *Materialize) RestoreSnapshot(store SnapshotStore) error {
+	data, err := store.Load()
+	if err != nil {
+		return err
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return fmt.Errorf("error decoding snapshot: %w", err)
+	}
+	return m.Restore(snapshot)
+}
+
+// RunSnapshots periodically saves snapshots until the context is done,
+// and saves a final snapshot before returning.
+func (m *Materialize) RunSnapshots(ctx context.Context, store SnapshotStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := m.SaveSnapshot(store); err != nil {
+				log.Error().Err(err).Msg("Failed to save final snapshot")
+			}
+			return
+		case <-ticker.C:
+			if err := m.SaveSnapshot(store); err != nil {
+				log.Error().Err(err).Msg("Failed to save snapshot")
+			}
+		}
+	}
+}
+
+// FollowSnapshots periodically restores the latest snapshot from the store
+// until the context is done, so another process can serve the state of the
+// materializer that writes the snapshots. Unchanged snapshots are skipped.
+func (m *Materialize) FollowSnapshots(ctx context.Context, store SnapshotStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var takenAt time.Time
+	for {
+		data, err := store.Load()
+		var snapshot Snapshot
+		if err == nil {
+			err = json.Unmarshal(data, &snapshot)
+		}
+		if err == nil && !snapshot.TakenAt.Equal(takenAt) {
+			err = m.Restore(snapshot)
+			takenAt = snapshot.TakenAt
+		}
+		if err != nil && !errors.Is(err, ErrNoSnapshot) {
+			log.Error().Err(err).Msg("Failed to follow snapshot")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// FileSnapshotStore stores snapshots in a local file.
+type FileSnapshotStore struct {
+	path string
+}
+
+// NewFileSnapshotStore creates a new FileSnapshotStore writing to the given path.
+func NewFileSnapshotStore(path string) *FileSnapshotStore {
+	return &FileSnapshotStore{path: path}
+}
+
+// Save atomically replaces the snapshot file.
+func (s *FileSnapshotStore) Save(data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing snapshot file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing snapshot file: %w", err)
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// Load reads the snapshot file.
+func (s *FileSnapshotStore) Load() ([]byte, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSnapshot
+	}
+	return data, err
+}
+
+// PostgresSnapshotStore stores snapshots in the materialize_snapshots table.
+type PostgresSnapshotStore struct {
+	db   *sql.DB
+	name string
+	lock *sql.Conn
+}
+
+// NewPostgresSnapshotStore creates a new PostgresSnapshotStore. Snapshots are
+// keyed by name, so several materializers can share the table.
+func NewPostgresSnapshotStore(databaseURL, name string) (*PostgresSnapshotStore, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &PostgresSnapshotStore{db: db, name: name}, nil
+}
+
+// Lock claims the snapshot for this process with an advisory lock held until
+// Close, so a single materializer writes it. It returns ErrSnapshotLocked if
+// another process holds the lock.
+func (s *PostgresSnapshotStore) Lock(ctx context.Context) error {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error connecting to database: %w", err)
+	}
+
+	var locked bool
+	err = conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock(hashtext($1))`, "materialize_snapshots/"+s.name).Scan(&locked)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("error locking snapshot: %w", err)
+	}
+	if !locked {
+		conn.Close()
+		return ErrSnapshotLocked
+	}
+	s.lock = conn
+	return nil
+}
+
+// Close releases the lock, if held, and closes the database connection.
+func (s *PostgresSnapshotStore) Close() error {
+	if s.lock != nil {
+		s.lock.Close()
+	}
+	return s.db.Close()
+}
+
+// Save upserts the snapshot.
+func (s *PostgresSnapshotStore) Save(data []byte) error {
+	query := `INSERT INTO materialize_snapshots (name, version, data, taken_at)
+		VALUES ($1, $2, $3, now())
+		ON CONFLICT (name) DO UPDATE SET version = EXCLUDED.version, data = EXCLUDED.data, taken_at = EXCLUDED.taken_at`
+	_, err := s.db.Exec(query, s.name, SnapshotVersion, data)
+	if err != nil {
+		return fmt.Errorf("error saving snapshot: %w", err)
+	}
+	return nil
+}
+
+// Load reads the snapshot.
+func (s *PostgresSnapshotStore) Load() ([]byte, error) {
+	var data []byte
+	err := s.db.QueryRow(`SELECT data FROM materialize_snapshots WHERE name = $1`, s.name).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoSnapshot
+	} else if err != nil {
+		return nil, fmt.Errorf("error loading snapshot: %w", err)
+	}
+	return data, nil
+}
+
+func copyCounts(src map[int]int) map[int]int {
+	if src == nil {
+		return nil
+	}
+	dst := make(map[int]int, len(src))
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+// copySketch returns a deep copy of the sketch, or nil if there is none.
+func copySketch(src *sketch.SpaceSaving) *sketch.SpaceSaving {
+	if src == nil {
+		return nil
+	}
+	data, _ := json.Marshal(src)
+	dst := &sketch.SpaceSaving{}
+	_ = json.Unmarshal(data, dst)
+	return dst
+}
@@ -0,0 +1,130 @@
+package materialize
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	store := NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshot.json"))
+
+	materializer := NewMaterialize()
+	if err := materializer.RestoreSnapshot(store); !errors.Is(err, ErrNoSnapshot) {
+		t.Fatalf("Expected ErrNoSnapshot, got %v", err)
+	}
+
+	now := time.Now()
+	events := []casino.Event{
+		{ID: 1, PlayerID: 10, Type: "game_start", GameID: 100, CreatedAt: now},
+		{ID: 2, PlayerID: 10, Type: "bet", GameID: 100, AmountEUR: 50, CreatedAt: now},
+		{ID: 3, PlayerID: 11, Type: "deposit", AmountEUR: 500, CreatedAt: now},
+	}
+	for _, event := range events {
+		materializer.UpdateStats(event)
+	}
+
+	if err := materializer.SaveSnapshot(store); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewMaterialize()
+	if err := restored.RestoreSnapshot(store); err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.LastEventID() != 3 {
+		t.Errorf("Expected LastEventID to be 3, got %d", restored.LastEventID())
+	}
+
+	if restored.stats != materializer.stats {
+		t.Errorf("Expected restored stats %+v, got %+v", materializer.stats, restored.stats)
+	}
+
+	profile, ok := restored.GetPlayer(10)
+	if !ok || profile.Bets != 1 || profile.LastHour.WageredEUR != 50 || profile.CurrentSession == nil {
+		t.Errorf("Unexpected restored player profile: %+v", profile)
+	}
+
+	// Restored state keeps being updated incrementally.
+	restored.UpdateStats(casino.Event{ID: 4, PlayerID: 11, Type: "deposit", AmountEUR: 100, CreatedAt: now})
+	if restored.stats.TopPlayerDeposits.Count != 600 {
+		t.Errorf("Expected TopPlayerDeposits count to be 600, got %d", restored.stats.TopPlayerDeposits.Count)
+	}
+}
+
+func TestRestoreNewerVersion(t *testing.T) {
+	snapshot := NewMaterialize().Snapshot()
+	snapshot.Version = SnapshotVersion + 1
+
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded Snapshot
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewMaterialize().Restore(decoded); err == nil {
+		t.Errorf("Expected error restoring snapshot version %d", decoded.Version)
+	}
+}
+
+func TestRestoreOlderVersion(t *testing.T) {
+	snapshot := NewMaterialize().Snapshot()
+	snapshot.Version = minSnapshotVersion - 1
+
+	if err := NewMaterialize().Restore(snapshot); err == nil {
+		t.Errorf("Expected error restoring snapshot version %d", snapshot.Version)
+	}
+}
+
+func TestRestoreMigratedVersion(t *testing.T) {
+	// Version 6 snapshots have no last event ID.
+	data := []byte(`{"version": 6, "state": {"stats": {"events_total": 2}, "players": {}}}`)
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := NewMaterialize()
+	if err := restored.Restore(snapshot); err != nil {
+		t.Fatal(err)
+	}
+	if restored.EventsTotal() != 2 || restored.LastEventID() != 0 {
+		t.Errorf("Expected 2 events up to event 0, got %d up to %d", restored.EventsTotal(), restored.LastEventID())
+	}
+}
+
+func TestFollowSnapshots(t *testing.T) {
+	store := NewFileSnapshotStore(filepath.Join(t.TempDir(), "snapshot.json"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	follower := NewMaterialize()
+	go follower.FollowSnapshots(ctx, store, 10*time.Millisecond)
+
+	materializer := NewMaterialize()
+	for id := 1; id <= 2; id++ {
+		materializer.UpdateStats(casino.Event{ID: id, PlayerID: 10, Type: "deposit", AmountEUR: 100, CreatedAt: time.Now()})
+		if err := materializer.SaveSnapshot(store); err != nil {
+			t.Fatal(err)
+		}
+
+		deadline := time.Now().Add(time.Second)
+		for follower.EventsTotal() != id && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if follower.EventsTotal() != id {
+			t.Fatalf("Expected follower to reach %d events, got %d", id, follower.EventsTotal())
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package materialize
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+// StreamMessage is pushed to streaming clients whenever the stats change.
+// The first message sent to a client is always a full snapshot, followed
+// by diffs containing only the changed top-level fields.
+type StreamMessage struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Streamer pushes materialized stats to SSE and WebSocket clients.
+// Stats are read under Materialize.mu only to take a copy; encoding and
+// writing to clients happens without holding the lock.
+type Streamer struct {
+	m        *Materialize
+	interval time.Duration
+	upgrader websocket.Upgrader
+
+	mu          sync.Mutex
+	clients     map[chan StreamMessage]struct{}
+	last        map[string]json.RawMessage
+	lastVersion uint64
+}
+
+// NewStreamer creates a new Streamer which checks for changes at most once per interval.
+func NewStreamer(m *Materialize, interval time.Duration) *Streamer {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &Streamer{
+		m:        m,
+		interval: interval,
+		clients:  make(map[chan StreamMessage]struct{}),
+	}
+}
+
+// Run broadcasts changes to the connected clients until the context is done.
+func (s *Streamer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.broadcast()
+		}
+	}
+}
+
+// broadcast sends a diff to all clients if the stats changed since the last broadcast.
+func (s *Streamer) broadcast() {
+	stats, version := s.m.snapshot()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if version == s.lastVersion {
+		return
+	}
+
+	current, err := fieldsOf(stats)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to encode stats for streaming")
+		return
+	}
+
+	diff := make(map[string]json.RawMessage)
+	for k, v := range current {
+		if !bytes.Equal(s.last[k], v) {
+			diff[k] = v
+		}
+	}
+	s.last = current
+	s.lastVersion = version
+
+	if len(diff) == 0 {
+		return
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return
+	}
+
+	msg := StreamMessage{Type: "diff", Data: data}
+	for ch := range s.clients {
+		select {
+		case ch <- msg:
+		default:
+			// The client is lagging behind, so replace its pending
+			// message with a snapshot to keep it consistent.
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- s.snapshotMessage()
+		}
+	}
+}
+
+// snapshotMessage returns the last broadcast state as a full snapshot.
+// It must be called with s.mu held.
+func (s *Streamer) snapshotMessage() StreamMessage {
+	data, _ := json.Marshal(s.last)
+	return StreamMessage{Type: "snapshot", Data: data}
+}
+
+// subscribe registers a new client and queues the current snapshot for it.
+func (s *Streamer) subscribe() chan StreamMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Clients always start from the last broadcast state, so the
+	// following diffs apply cleanly.
+	if s.last == nil {
+		stats, version := s.m.snapshot()
+		if current, err := fieldsOf(stats); err == nil {
+			s.last = current
+			s.lastVersion = version
+		}
+	}
+
+	ch := make(chan StreamMessage, 1)
+	ch <- s.snapshotMessage()
+	s.clients[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a client.
+func (s *Streamer) unsubscribe(ch chan StreamMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.clients, ch)
+}
+
+// ServeSSE handles HTTP requests streaming the materialized data as Server-Sent Events.
+func (s *Streamer) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-ch:
+			_, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", msg.Type, msg.Data)
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// ServeWebSocket handles HTTP requests streaming the materialized data over a WebSocket.
+func (s *Streamer) ServeWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to upgrade WebSocket connection")
+		return
+	}
+	defer conn.Close()
+
+	ch := s.subscribe()
+	defer s.unsubscribe(ch)
+
+	// Read until the client goes away, so close frames are handled.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-done:
+			return
+		case msg := <-ch:
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package materialize
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/gorilla/websocket"
+)
+
+func TestStreamerBroadcast(t *testing.T) {
+	materializer := NewMaterialize()
+	streamer := NewStreamer(materializer, time.Second)
+
+	ch := streamer.subscribe()
+	msg := <-ch
+	if msg.Type != "snapshot" {
+		t.Fatalf("Expected first message to be a snapshot, got %s", msg.Type)
+	}
+
+	materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "bet", CreatedAt: time.Now()})
+	streamer.broadcast()
+
+	msg = <-ch
+	if msg.Type != "diff" {
+		t.Fatalf("Expected a diff, got %s", msg.Type)
+	}
+
+	var diff map[string]json.RawMessage
+	if err := json.Unmarshal(msg.Data, &diff); err != nil {
+		t.Fatal(err)
+	}
+	if string(diff["events_total"]) != "1" {
+		t.Errorf("Expected events_total to be 1 in diff, got %s", diff["events_total"])
+	}
+	if _, ok := diff["top_player_deposits"]; ok {
+		t.Errorf("Expected unchanged top_player_deposits to be left out of diff")
+	}
+
+	// No change since the last broadcast, so nothing is sent.
+	streamer.broadcast()
+	select {
+	case msg = <-ch:
+		t.Errorf("Expected no message, got %+v", msg)
+	default:
+	}
+}
+
+func TestServeSSEAndWebSocket(t *testing.T) {
+	materializer := NewMaterialize()
+	materializer.UpdateStats(casino.Event{PlayerID: 1, Type: "bet", CreatedAt: time.Now()})
+	streamer := NewStreamer(materializer, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go streamer.Run(ctx)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/materialized/stream", streamer.ServeSSE)
+	mux.HandleFunc("/materialized/ws", streamer.ServeWebSocket)
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/materialized/stream")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "event: snapshot\n" {
+		t.Errorf("Expected snapshot event, got %q", line)
+	}
+	line, _ = reader.ReadString('\n')
+	if !strings.Contains(line, `"events_total":1`) {
+		t.Errorf("Expected snapshot data with events_total, got %q", line)
+	}
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/materialized/ws", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var msg StreamMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "snapshot" {
+		t.Errorf("Expected snapshot message, got %s", msg.Type)
+	}
+
+	materializer.UpdateStats(casino.Event{PlayerID: 2, Type: "deposit", AmountEUR: 100, CreatedAt: time.Now()})
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatal(err)
+	}
+	if msg.Type != "diff" || !strings.Contains(string(msg.Data), `"events_total":2`) {
+		t.Errorf("Expected diff with events_total 2, got %s %s", msg.Type, msg.Data)
+	}
+}
@@ -0,0 +1,283 @@
+// Package metrics This exposes pipeline and business metrics in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the histogram buckets used for latencies in seconds.
+var DefaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector writes metrics in the Prometheus text format.
+type Collector interface {
+	Collect(w io.Writer)
+}
+
+// Registry holds the registered collectors.
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+}
+
+// DefaultRegistry is the registry used by the package-level constructors.
+var DefaultRegistry = &Registry{}
+
+// Register adds collectors to the registry.
+func (r *Registry) Register(collectors ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, collectors...)
+}
+
+// Collect writes all registered metrics.
+func (r *Registry) Collect(w io.Writer) {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	r.mu.Unlock()
+
+	for _, c := range collectors {
+		c.Collect(w)
+	}
+}
+
+// Handler serves the default registry and the given extra collectors.
+func Handler(extra ...Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		DefaultRegistry.Collect(w)
+		for _, c := range extra {
+			c.Collect(w)
+		}
+	}
+}
+
+// vec holds values of a metric keyed by label values.
+type vec struct {
+	mu     sync.Mutex
+	name   string
+	help   string
+	labels []string
+	values map[string][]string
+}
+
+func newVec(name, help string, labels []string) vec {
+	return vec{name: name, help: help, labels: labels, values: make(map[string][]string)}
+}
+
+// key returns the map key for the given label values and records them as a series.
+func (v *vec) key(labelValues []string) string {
+	key := v.lookup(labelValues)
+	if _, ok := v.values[key]; !ok {
+		v.values[key] = append([]string(nil), labelValues...)
+	}
+	return key
+}
+
+// lookup returns the map key for the given label values without recording a series.
+func (v *vec) lookup(labelValues []string) string {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.name, len(v.labels), len(labelValues)))
+	}
+	return strings.Join(labelValues, "\xff")
+}
+
+// sortedKeys returns the label keys in a stable order.
+func (v *vec) sortedKeys() []string {
+	keys := make([]string, 0, len(v.values))
+	for k := range v.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Counter is a monotonically increasing metric.
+type Counter struct {
+	vec
+	counts map[string]float64
+}
+
+// NewCounter creates a counter and registers it in the default registry.
+func NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{vec: newVec(name, help, labels), counts: make(map[string]float64)}
+	DefaultRegistry.Register(c)
+	return c
+}
+
+// Inc increments the counter by one.
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add increments the counter by the given value.
+func (c *Counter) Add(value float64, labelValues ...string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts[c.key(labelValues)] += value
+}
+
+// Value returns the current value of the counter. Reading a series that was never
+// incremented does not add it to the output.
+func (c *Counter) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.counts[c.lookup(labelValues)]
+}
+
+// Collect writes the counter.
+func (c *Counter) Collect(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	writeHeader(w, c.name, c.help, "counter")
+	for _, k := range c.sortedKeys() {
+		writeSample(w, c.name, c.labels, c.values[k], nil, c.counts[k])
+	}
+}
+
+// Gauge is a metric that can go up and down.
+type Gauge struct {
+	vec
+	gauges map[string]float64
+}
+
+// NewGauge creates a gauge and registers it in the default registry.
+func NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{vec: newVec(name, help, labels), gauges: make(map[string]float64)}
+	DefaultRegistry.Register(g)
+	return g
+}
+
+// Set sets the gauge to the given value.
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gauges[g.key(labelValues)] = value
+}
+
+// Collect writes the gauge.
+func (g *Gauge) Collect(w io.Writer) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	writeHeader(w, g.name, g.help, "gauge")
+	for _, k := range g.sortedKeys() {
+		writeSample(w, g.name, g.labels, g.values[k], nil, g.gauges[k])
+	}
+}
+
+// Histogram counts observations in configurable buckets.
+type Histogram struct {
+	vec
+	buckets []float64
+	counts  map[string][]uint64
+	sums    map[string]float64
+	totals  map[string]uint64
+}
+
+// NewHistogram creates a histogram and registers it in the default registry.
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{
+		vec:     newVec(name, help, labels),
+		buckets: buckets,
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		totals:  make(map[string]uint64),
+	}
+	DefaultRegistry.Register(h)
+	return h
+}
+
+// Observe records a single observation.
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	k := h.key(labelValues)
+	counts, ok := h.counts[k]
+	if !ok {
+		counts = make([]uint64, len(h.buckets))
+		h.counts[k] = counts
+	}
+	for i, upper := range h.buckets {
+		if value <= upper {
+			counts[i]++
+		}
+	}
+	h.sums[k] += value
+	h.totals[k]++
+}
+
+// Collect writes the histogram.
+func (h *Histogram) Collect(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.name, h.help, "histogram")
+	for _, k := range h.sortedKeys() {
+		labelValues := h.values[k]
+		for i, upper := range h.buckets {
+			writeSample(w, h.name+"_bucket", h.labels, labelValues, []string{"le", formatFloat(upper)}, float64(h.counts[k][i]))
+		}
+		writeSample(w, h.name+"_bucket", h.labels, labelValues, []string{"le", "+Inf"}, float64(h.totals[k]))
+		writeSample(w, h.name+"_sum", h.labels, labelValues, nil, h.sums[k])
+		writeSample(w, h.name+"_count", h.labels, labelValues, nil, float64(h.totals[k]))
+	}
+}
+
+// WriteGauge writes a single unlabelled gauge, for collectors computing values on demand.
+func WriteGauge(w io.Writer, name, help string, value float64) {
+	writeHeader(w, name, help, "gauge")
+	writeSample(w, name, nil, nil, nil, value)
+}
+
+// WriteCounter writes a single unlabelled counter, for collectors computing values on demand.
+func WriteCounter(w io.Writer, name, help string, value float64) {
+	writeHeader(w, name, help, "counter")
+	writeSample(w, name, nil, nil, nil, value)
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, typ)
+}
+
+func writeSample(w io.Writer, name string, labels, labelValues, extra []string, value float64) {
+	var pairs []string
+	for i, l := range labels {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", l, escapeLabel(labelValues[i])))
+	}
+	if extra != nil {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", extra[0], escapeLabel(extra[1])))
+	}
+
+	if len(pairs) > 0 {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, strings.Join(pairs, ","), formatFloat(value))
+		return
+	}
+	fmt.Fprintf(w, "%s %s\n", name, formatFloat(value))
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCollect(t *testing.T) {
+	registry := &Registry{}
+
+	counter := &Counter{vec: newVec("test_events_total", "Test events.", []string{"type"}), counts: make(map[string]float64)}
+	histogram := &Histogram{
+		vec:     newVec("test_duration_seconds", "Test duration.", nil),
+		buckets: []float64{0.1, 1},
+		counts:  make(map[string][]uint64),
+		sums:    make(map[string]float64),
+		totals:  make(map[string]uint64),
+	}
+	registry.Register(counter, histogram)
+
+	counter.Inc("bet")
+	counter.Add(2, "deposit")
+	counter.Inc("say \"hi\"")
+	histogram.Observe(0.05)
+	histogram.Observe(0.5)
+	histogram.Observe(5)
+
+	var buf bytes.Buffer
+	registry.Collect(&buf)
+
+	expected := []string{
+		"# TYPE test_events_total counter",
+		`test_events_total{type="bet"} 1`,
+		`test_events_total{type="deposit"} 2`,
+		`test_events_total{type="say \"hi\""} 1`,
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{le="0.1"} 1`,
+		`test_duration_seconds_bucket{le="1"} 2`,
+		`test_duration_seconds_bucket{le="+Inf"} 3`,
+		"test_duration_seconds_sum 5.55",
+		"test_duration_seconds_count 3",
+	}
+	for _, line := range expected {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	EventsPublished.Inc("bet")
+
+	req, err := http.NewRequest("GET", "/metrics", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, req)
+
+	if status := rr.Code; status != http.StatusOK {
+		t.Errorf("Handler returned wrong status code: got %v want %v", status, http.StatusOK)
+	}
+
+	if !strings.Contains(rr.Body.String(), `casino_events_published_total{type="bet"}`) {
+		t.Errorf("Expected published events counter in output, got:\n%s", rr.Body.String())
+	}
+}
+
+func TestCounterValueDoesNotAddSeries(t *testing.T) {
+	counter := &Counter{vec: newVec("test_reads_total", "Test reads.", []string{"type"}), counts: make(map[string]float64)}
+
+	if v := counter.Value("bet"); v != 0 {
+		t.Errorf("Expected 0 for an unseen series, got %v", v)
+	}
+
+	var buf bytes.Buffer
+	counter.Collect(&buf)
+	if strings.Contains(buf.String(), `type="bet"`) {
+		t.Errorf("Expected Value not to add a series, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,23 @@
+package metrics
+
+// Pipeline metrics shared by the publisher, subscriber and enrichment components.
+var (
+	EventsPublished = NewCounter("casino_events_published_total", "Number of events published to the broker.", "type")
+	EventsConsumed  = NewCounter("casino_events_consumed_total", "Number of events consumed from the broker.", "type")
+	EventsFailed    = NewCounter("casino_events_failed_total", "Number of events that failed to be published or consumed.", "stage", "type")
+	EventsDropped   = NewCounter("casino_events_dropped_total", "Number of buffered events a sink dropped after failing to write them.", "sink")
+
+	EnrichmentDuration = NewHistogram("casino_enrichment_duration_seconds", "Time spent enriching an event.", DefaultBuckets, "enricher")
+	DBLookupDuration   = NewHistogram("casino_db_lookup_duration_seconds", "Time spent looking up a player in the database.", DefaultBuckets)
+
+	ExchangeRateCache = NewCounter("casino_exchange_rate_cache_total", "Number of exchange rate cache lookups by result.", "result")
+
+	BreakerState = NewGauge("casino_breaker_state", "State of a circuit breaker: 0 closed, 1 half open, 2 open.", "breaker")
+	BreakerCalls = NewCounter("casino_breaker_calls_total", "Number of calls through a circuit breaker by result.", "breaker", "result")
+
+	GeneratorTargetRate   = NewGauge("casino_generator_target_events_per_second", "Target rate of the generator load profile.")
+	GeneratorAchievedRate = NewGauge("casino_generator_achieved_events_per_second", "Rate the generator achieved over the last report interval.")
+
+	QueueLag = NewHistogram("casino_queue_lag_seconds", "Time between event creation and consumption.",
+		[]float64{.01, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60}, "type")
+)
@@ -4,63 +4,192 @@ package pubsub
 
 import (
 	"encoding/json"
-	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"sync"
+
 	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
 	"github.com/rs/zerolog/log"
 
 	"github.com/streadway/amqp"
 )
 
+// EventsQueue is the queue of casino events.
+const EventsQueue = "casino_events"
+
+// Publisher publishes messages to a RabbitMQ queue. It keeps one connection
+// open, redialing when it was lost, and must be closed.
+type Publisher struct {
+	url   string
+	queue string
+
+	mu   sync.Mutex
+	conn *amqp.Connection
+	ch   *amqp.Channel
+}
+
+// NewPublisher creates a new Publisher of events connecting to the RabbitMQ URL.
+func NewPublisher(url string) *Publisher {
+	return NewQueuePublisher(url, EventsQueue)
+}
+
+// NewQueuePublisher creates a new Publisher to the given queue connecting to the RabbitMQ URL.
+func NewQueuePublisher(url, queue string) *Publisher {
+	return &Publisher{url: url, queue: queue}
+}
+
 // PublishEvent sends generated events to RabbitMQ
-func PublishEvent(event casino.Event) error {
+func (p *Publisher) PublishEvent(event casino.Event) (err error) {
+	defer func() {
+		if err != nil {
+			metrics.EventsFailed.Inc("publish", event.Type)
+			return
+		}
+		metrics.EventsPublished.Inc(event.Type)
+	}()
+
+	// Serialize the event to JSON
+	eventJSON, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	if err := p.publish(eventJSON); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Published event: %s", string(eventJSON))
+	return nil
+}
+
+// PublishJSON sends the value as JSON, counted in the metrics as the given type
+func (p *Publisher) PublishJSON(typ string, v any) (err error) {
+	defer func() {
+		if err != nil {
+			metrics.EventsFailed.Inc("publish", typ)
+			return
+		}
+		metrics.EventsPublished.Inc(typ)
+	}()
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if err := p.publish(body); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Published %s to %s: %s", typ, p.queue, string(body))
+	return nil
+}
+
+// PublishRaw sends the body to RabbitMQ as is, e.g. to test how consumers
+// handle malformed messages
+func (p *Publisher) PublishRaw(body []byte) (err error) {
+	defer func() {
+		if err != nil {
+			metrics.EventsFailed.Inc("publish", "raw")
+			return
+		}
+		metrics.EventsPublished.Inc("raw")
+	}()
+
+	if err := p.publish(body); err != nil {
+		return err
+	}
+
+	log.Info().Msgf("Published raw message: %q", body)
+	return nil
+}
+
+// Ping checks the connection to RabbitMQ, connecting if there is none.
+func (p *Publisher) Ping() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.connect()
+}
+
+// Close closes the connection to RabbitMQ.
+func (p *Publisher) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.disconnect()
+}
+
+// connect opens the connection and channel and declares the queue, unless
+// the connection is still open. Callers hold mu.
+func (p *Publisher) connect() error {
+	if p.conn != nil && !p.conn.IsClosed() {
+		return nil
+	}
+	p.disconnect()
+
 	// Connect to RabbitMQ
-	conn, err := amqp.Dial(config.RabbitMQURL)
+	conn, err := amqp.Dial(p.url)
 	if err != nil {
 		return err
 	}
-	defer conn.Close()
 
 	ch, err := conn.Channel()
 	if err != nil {
+		conn.Close()
 		return err
 	}
-	defer ch.Close()
 
 	// Declare a queue for event publishing
-	q, err := ch.QueueDeclare(
-		"casino_events", // Queue name
-		true,            // Durable
-		false,           // Auto-delete
-		false,           // Exclusive
-		false,           // No-wait
-		nil,             // Arguments
+	_, err = ch.QueueDeclare(
+		p.queue, // Queue name
+		true,    // Durable
+		false,   // Auto-delete
+		false,   // Exclusive
+		false,   // No-wait
+		nil,     // Arguments
 	)
 	if err != nil {
+		conn.Close()
 		return err
 	}
 
-	// Serialize the event to JSON
-	eventJSON, err := json.Marshal(event)
-	if err != nil {
+	p.conn, p.ch = conn, ch
+	return nil
+}
+
+// disconnect closes the connection, if any. Callers hold mu.
+func (p *Publisher) disconnect() error {
+	if p.conn == nil {
+		return nil
+	}
+	err := p.conn.Close()
+	p.conn, p.ch = nil, nil
+	if err == amqp.ErrClosed {
+		return nil
+	}
+	return err
+}
+
+// publish sends the body to the queue, reconnecting if the connection was lost
+func (p *Publisher) publish(body []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.connect(); err != nil {
 		return err
 	}
 
 	// Publish event to RabbitMQ
-	err = ch.Publish(
-		"",     // Exchange
-		q.Name, // Routing key (queue name)
-		false,  // Mandatory
-		false,  // Immediate
+	err := p.ch.Publish(
+		"",      // Exchange
+		p.queue, // Routing key (queue name)
+		false,   // Mandatory
+		false,   // Immediate
 		amqp.Publishing{
 			ContentType: "application/json",
-			Body:        eventJSON,
+			Body:        body,
 		},
 	)
-
 	if err != nil {
-		return err
+		// The channel is closed after an error, start over on the next message
+		p.disconnect()
 	}
-
-	log.Info().Msgf("Published event: %s", string(eventJSON))
-	return nil
+	return err
 }
@@ -3,22 +3,52 @@
 package pubsub
 
 import (
+	"context"
 	"encoding/json"
-	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"fmt"
 	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
 	"github.com/rs/zerolog/log"
 	"github.com/streadway/amqp"
+	"sync"
+	"time"
 )
 
-// SubscribeEvents listens for incoming events from RabbitMQ
-func SubscribeEvents(processEvent func(event casino.Event)) error {
+// Subscriber consumes events from the casino_events queue.
+type Subscriber struct {
+	url string
+
+	mu           sync.Mutex
+	conn         *amqp.Connection
+	connected    bool
+	subscribed   time.Time
+	lastConsumed time.Time
+}
+
+// NewSubscriber creates a new Subscriber connecting to the RabbitMQ URL.
+func NewSubscriber(url string) *Subscriber {
+	return &Subscriber{url: url}
+}
+
+// SubscribeEvents listens for incoming events from RabbitMQ until the context is done
+func (s *Subscriber) SubscribeEvents(ctx context.Context, processEvent func(event casino.Event)) error {
 	// Connect to RabbitMQ
-	conn, err := amqp.Dial(config.RabbitMQURL)
+	conn, err := amqp.Dial(s.url)
 	if err != nil {
 		return err
 	}
 	defer conn.Close()
 
+	// Track the connection for health checks
+	s.setConnected(conn)
+	closed := conn.NotifyClose(make(chan *amqp.Error, 1))
+	go func() {
+		if err := <-closed; err != nil {
+			log.Error().Err(err).Msg("Lost connection to RabbitMQ")
+		}
+		s.setConnected(nil)
+	}()
+
 	ch, err := conn.Channel()
 	if err != nil {
 		return err
@@ -27,7 +57,7 @@ func SubscribeEvents(processEvent func(event casino.Event)) error {
 
 	// Declare queue before consuming
 	q, err := ch.QueueDeclare(
-		"casino_events",
+		EventsQueue,
 		true, false, false, false, nil,
 	)
 	if err != nil {
@@ -41,18 +71,88 @@ func SubscribeEvents(processEvent func(event casino.Event)) error {
 	}
 
 	// Process each message
+	done := make(chan struct{})
 	go func() {
+		defer close(done)
 		for d := range msgs {
 			var event casino.Event
 			err := json.Unmarshal(d.Body, &event)
 			if err != nil {
 				log.Error().Err(err).Msg("Failed to unmarshal event")
+				metrics.EventsFailed.Inc("consume", "unknown")
 				continue
 			}
+			metrics.EventsConsumed.Inc(event.Type)
+			lag := time.Since(event.CreatedAt)
+			metrics.QueueLag.Observe(lag.Seconds(), event.Type)
+			s.consumed()
 			processEvent(event)
 		}
 	}()
 
 	log.Info().Msg("Subscribed to events")
-	select {} // Keep the function running
+	<-ctx.Done()
+
+	// Stop the deliveries and let the event being processed finish
+	ch.Close()
+	<-done
+	return nil
+}
+
+// Connected reports whether the subscriber is connected to RabbitMQ.
+func (s *Subscriber) Connected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// Pending returns the number of messages waiting in the queue, inspected
+// over the connection of the subscriber.
+func (s *Subscriber) Pending() (int, error) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return 0, fmt.Errorf("not connected to RabbitMQ")
+	}
+
+	// Inspect on a channel of its own, which the broker closes if it fails
+	ch, err := conn.Channel()
+	if err != nil {
+		return 0, err
+	}
+	defer ch.Close()
+
+	q, err := ch.QueueInspect(EventsQueue)
+	if err != nil {
+		return 0, err
+	}
+	return q.Messages, nil
+}
+
+// LastConsumed returns when the last event was consumed, or when the
+// subscriber subscribed if no event was consumed yet.
+func (s *Subscriber) LastConsumed() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.lastConsumed.IsZero() {
+		return s.subscribed
+	}
+	return s.lastConsumed
+}
+
+func (s *Subscriber) setConnected(conn *amqp.Connection) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn = conn
+	s.connected = conn != nil
+	if conn != nil {
+		s.subscribed = time.Now()
+	}
+}
+
+func (s *Subscriber) consumed() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastConsumed = time.Now()
 }
@@ -0,0 +1,123 @@
+// Package replay This reads stored events and replays them at a configurable speed.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+// Source reads events created within [from, to) in order of creation.
+// A zero from or to leaves that side of the range open.
+type Source interface {
+	ReadEvents(ctx context.Context, from, to time.Time, fn func(casino.Event) error) error
+}
+
+// JSONLSource reads events from files with one JSON event per line.
+// Events are expected in order of creation within and across files.
+type JSONLSource struct {
+	Paths []string
+}
+
+// ReadEvents reads the files in order and calls fn for every event within the range.
+func (s JSONLSource) ReadEvents(ctx context.Context, from, to time.Time, fn func(casino.Event) error) error {
+	for _, path := range s.Paths {
+		if err := readJSONL(ctx, path, from, to, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readJSONL(ctx context.Context, path string, from, to time.Time, fn func(casino.Event) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("error opening %s: %w", path, err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var event casino.Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("error decoding %s:%d: %w", path, line, err)
+		}
+		if !from.IsZero() && event.CreatedAt.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !event.CreatedAt.Before(to) {
+			continue
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Replayer replays events from a source into a handler.
+type Replayer struct {
+	// Speed scales the original timing between events: 1 replays in real
+	// time, 2 twice as fast. Zero or less replays as fast as possible.
+	Speed float64
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// NewReplayer creates a new Replayer with the given speed.
+func NewReplayer(speed float64) *Replayer {
+	return &Replayer{Speed: speed, sleep: sleepContext}
+}
+
+// Replay reads the events within [from, to) from the source and passes them
+// to handle, waiting between events according to the speed. It returns the
+// number of replayed events.
+func (r *Replayer) Replay(ctx context.Context, source Source, from, to time.Time, handle func(casino.Event) error) (int, error) {
+	var count int
+	var previous time.Time
+
+	err := source.ReadEvents(ctx, from, to, func(event casino.Event) error {
+		if r.Speed > 0 && !previous.IsZero() {
+			if gap := event.CreatedAt.Sub(previous); gap > 0 {
+				if err := r.sleep(ctx, time.Duration(float64(gap)/r.Speed)); err != nil {
+					return err
+				}
+			}
+		}
+		previous = event.CreatedAt
+
+		if err := handle(event); err != nil {
+			return err
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,67 @@
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+)
+
+func TestReplayJSONL(t *testing.T) {
+	start := time.Date(2025, 2, 19, 20, 0, 0, 0, time.UTC)
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := json.NewEncoder(f)
+	for i := 0; i < 5; i++ {
+		event := casino.Event{ID: i + 1, PlayerID: 10, Type: "bet", CreatedAt: start.Add(time.Duration(i) * time.Minute)}
+		if err := encoder.Encode(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	var slept []time.Duration
+	replayer := NewReplayer(2)
+	replayer.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+
+	var ids []int
+	count, err := replayer.Replay(context.Background(), JSONLSource{Paths: []string{path}},
+		start.Add(time.Minute), start.Add(4*time.Minute),
+		func(event casino.Event) error {
+			ids = append(ids, event.ID)
+			return nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if count != 3 || len(ids) != 3 || ids[0] != 2 || ids[2] != 4 {
+		t.Errorf("Expected events 2 to 4 to be replayed, got %v", ids)
+	}
+
+	// Gaps of one minute are halved at speed 2.
+	if len(slept) != 2 || slept[0] != 30*time.Second || slept[1] != 30*time.Second {
+		t.Errorf("Expected two waits of 30s, got %v", slept)
+	}
+
+	slept = nil
+	replayer.Speed = 0
+	if _, err := replayer.Replay(context.Background(), JSONLSource{Paths: []string{path}}, time.Time{}, time.Time{},
+		func(casino.Event) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(slept) != 0 {
+		t.Errorf("Expected no waits as fast as possible, got %v", slept)
+	}
+}
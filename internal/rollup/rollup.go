@@ -0,0 +1,292 @@
+// Package rollup This persists hourly and daily aggregates of events into Postgres.
+package rollup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+	"github.com/lib/pq" // PostgreSQL driver
+	"github.com/rs/zerolog/log"
+)
+
+// Periods aggregated by the sink, with the length of their buckets.
+var Periods = map[string]time.Duration{
+	"hour": time.Hour,
+	"day":  24 * time.Hour,
+}
+
+// Totals holds the aggregated values of a rollup row.
+type Totals struct {
+	Events      int
+	Bets        int
+	WageredEUR  int
+	Deposits    int
+	DepositsEUR int
+	Wins        int
+	WonEUR      int
+}
+
+// Key identifies a rollup row. ID holds the player or game ID, and Currency
+// the currency, depending on the table the row belongs to.
+type Key struct {
+	Table    string
+	Period   string
+	Bucket   time.Time
+	ID       int
+	Currency string
+}
+
+// Aggregate sums the events into rollup rows per player, game and currency,
+// for every period.
+func Aggregate(events []casino.Event) map[Key]*Totals {
+	rows := make(map[Key]*Totals)
+	add := func(key Key, event casino.Event) {
+		t, ok := rows[key]
+		if !ok {
+			t = &Totals{}
+			rows[key] = t
+		}
+		t.Events++
+		switch event.Type {
+		case "bet":
+			t.Bets++
+			t.WageredEUR += event.AmountEUR
+			if event.HasWon {
+				t.Wins++
+				t.WonEUR += event.WinAmountEUR
+			}
+		case "deposit":
+			t.Deposits++
+			t.DepositsEUR += event.AmountEUR
+		}
+	}
+
+	for _, event := range events {
+		for period, length := range Periods {
+			bucket := event.CreatedAt.UTC().Truncate(length)
+			add(Key{Table: "player_rollups", Period: period, Bucket: bucket, ID: event.PlayerID}, event)
+			if event.GameID != 0 {
+				add(Key{Table: "game_rollups", Period: period, Bucket: bucket, ID: event.GameID}, event)
+			}
+			if event.Currency != "" {
+				add(Key{Table: "currency_rollups", Period: period, Bucket: bucket, Currency: event.Currency}, event)
+			}
+		}
+	}
+	return rows
+}
+
+const (
+	// maxAttempts is the number of failed flushes after which the buffered
+	// events are dropped.
+	maxAttempts = 5
+	// maxBuffered bounds the buffer while writes fail. The oldest events
+	// beyond it are dropped.
+	maxBuffered = 100000
+)
+
+// Sink buffers events and periodically upserts their aggregates into Postgres.
+// Each event is recorded in rollup_processed_events in the same transaction,
+// so redelivered events are not counted twice.
+type Sink struct {
+	db *sql.DB
+
+	mu       sync.Mutex
+	buffer   []casino.Event
+	attempts int
+}
+
+// NewSink creates a new Sink connected to the given database.
+func NewSink(databaseURL string) (*Sink, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &Sink{db: db}, nil
+}
+
+// Close closes the database connection.
+func (s *Sink) Close() error {
+	return s.db.Close()
+}
+
+// Add buffers an event until the next flush.
+func (s *Sink) Add(event casino.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffer = append(s.buffer, event)
+	s.trim()
+}
+
+// Run flushes the buffer periodically until the context is done,
+// and flushes once more before returning.
+func (s *Sink) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := s.Flush(context.Background()); err != nil {
+				log.Error().Err(err).Msg("Failed to flush rollups")
+			}
+			return
+		case <-ticker.C:
+			if err := s.Flush(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to flush rollups")
+			}
+		}
+	}
+}
+
+// Flush writes the buffered events. On failure the events are kept for the
+// next flush, up to maxAttempts flushes and maxBuffered events.
+func (s *Sink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	events := s.buffer
+	s.buffer = nil
+	s.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	err := s.write(ctx, events)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err == nil {
+		s.attempts = 0
+		return nil
+	}
+	s.attempts++
+	if s.attempts >= maxAttempts {
+		s.attempts = 0
+		drop(len(events), err)
+		return err
+	}
+	s.buffer = append(events, s.buffer...)
+	s.trim()
+	return err
+}
+
+// trim drops the oldest events beyond maxBuffered. Callers hold mu.
+func (s *Sink) trim() {
+	if excess := len(s.buffer) - maxBuffered; excess > 0 {
+		drop(excess, nil)
+		s.buffer = s.buffer[excess:]
+	}
+}
+
+// drop logs and counts events that won't be aggregated.
+func drop(n int, err error) {
+	log.Error().Err(err).Msgf("Dropped %d events that could not be rolled up", n)
+	metrics.EventsDropped.Add(float64(n), "rollup")
+}
+
+func (s *Sink) write(ctx context.Context, events []casino.Event) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	fresh, err := markProcessed(ctx, tx, events)
+	if err != nil {
+		return err
+	}
+
+	for key, totals := range Aggregate(fresh) {
+		if err := upsert(ctx, tx, key, totals); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing rollups: %w", err)
+	}
+	return nil
+}
+
+// markProcessed records the events as processed and returns those not processed before.
+func markProcessed(ctx context.Context, tx *sql.Tx, events []casino.Event) ([]casino.Event, error) {
+	ids := make([]int64, len(events))
+	createdAt := make([]string, len(events))
+	for i, event := range events {
+		ids[i] = int64(event.ID)
+		createdAt[i] = event.CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	query := `INSERT INTO rollup_processed_events (event_id, created_at)
+		SELECT * FROM unnest($1::bigint[], $2::timestamptz[])
+		ON CONFLICT DO NOTHING
+		RETURNING event_id, created_at`
+	rows, err := tx.QueryContext(ctx, query, pq.Array(ids), pq.Array(createdAt))
+	if err != nil {
+		return nil, fmt.Errorf("error marking events as processed: %w", err)
+	}
+	defer rows.Close()
+
+	type eventKey struct {
+		id        int
+		createdAt int64
+	}
+	inserted := make(map[eventKey]bool)
+	for rows.Next() {
+		var id int
+		var t time.Time
+		if err := rows.Scan(&id, &t); err != nil {
+			return nil, fmt.Errorf("error marking events as processed: %w", err)
+		}
+		inserted[eventKey{id, t.UnixNano() / 1000}] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error marking events as processed: %w", err)
+	}
+
+	// Postgres keeps microseconds, so compare timestamps at that precision.
+	var fresh []casino.Event
+	for _, event := range events {
+		key := eventKey{event.ID, event.CreatedAt.UnixNano() / 1000}
+		if inserted[key] {
+			fresh = append(fresh, event)
+			delete(inserted, key)
+		}
+	}
+	return fresh, nil
+}
+
+func upsert(ctx context.Context, tx *sql.Tx, key Key, t *Totals) error {
+	column, value := "player_id", interface{}(key.ID)
+	switch key.Table {
+	case "game_rollups":
+		column = "game_id"
+	case "currency_rollups":
+		column, value = "currency", key.Currency
+	}
+
+	query := fmt.Sprintf(`INSERT INTO %[1]s (period, bucket, %[2]s, events, bets, wagered_eur, deposits, deposits_eur, wins, won_eur)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		ON CONFLICT (period, bucket, %[2]s) DO UPDATE SET
+			events = %[1]s.events + EXCLUDED.events,
+			bets = %[1]s.bets + EXCLUDED.bets,
+			wagered_eur = %[1]s.wagered_eur + EXCLUDED.wagered_eur,
+			deposits = %[1]s.deposits + EXCLUDED.deposits,
+			deposits_eur = %[1]s.deposits_eur + EXCLUDED.deposits_eur,
+			wins = %[1]s.wins + EXCLUDED.wins,
+			won_eur = %[1]s.won_eur + EXCLUDED.won_eur`, key.Table, column)
+
+	_, err := tx.ExecContext(ctx, query, key.Period, key.Bucket, value,
+		t.Events, t.Bets, t.WageredEUR, t.Deposits, t.DepositsEUR, t.Wins, t.WonEUR)
+	if err != nil {
+		return fmt.Errorf("error upserting %s: %w", key.Table, err)
+	}
+	return nil
+}
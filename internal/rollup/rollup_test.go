@@ -0,0 +1,86 @@
+package rollup
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+)
+
+func TestAggregate(t *testing.T) {
+	at := time.Date(2025, 2, 19, 20, 50, 0, 0, time.UTC)
+	events := []casino.Event{
+		{ID: 1, PlayerID: 10, GameID: 100, Type: "game_start", CreatedAt: at},
+		{ID: 2, PlayerID: 10, GameID: 100, Type: "bet", Currency: "EUR", AmountEUR: 300, HasWon: true, WinAmountEUR: 600, CreatedAt: at.Add(5 * time.Minute)},
+		{ID: 3, PlayerID: 10, GameID: 100, Type: "game_stop", CreatedAt: at.Add(15 * time.Minute)},
+		{ID: 4, PlayerID: 11, Type: "deposit", Currency: "EUR", AmountEUR: 1000, CreatedAt: at.Add(20 * time.Minute)},
+	}
+
+	rows := Aggregate(events)
+
+	hour := time.Date(2025, 2, 19, 20, 0, 0, 0, time.UTC)
+	nextHour := hour.Add(time.Hour)
+	day := time.Date(2025, 2, 19, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		key      Key
+		expected Totals
+	}{
+		{Key{Table: "player_rollups", Period: "hour", Bucket: hour, ID: 10}, Totals{Events: 2, Bets: 1, WageredEUR: 300, Wins: 1, WonEUR: 600}},
+		{Key{Table: "player_rollups", Period: "hour", Bucket: nextHour, ID: 10}, Totals{Events: 1}},
+		{Key{Table: "player_rollups", Period: "day", Bucket: day, ID: 10}, Totals{Events: 3, Bets: 1, WageredEUR: 300, Wins: 1, WonEUR: 600}},
+		{Key{Table: "game_rollups", Period: "day", Bucket: day, ID: 100}, Totals{Events: 3, Bets: 1, WageredEUR: 300, Wins: 1, WonEUR: 600}},
+		{Key{Table: "currency_rollups", Period: "day", Bucket: day, Currency: "EUR"}, Totals{Events: 2, Bets: 1, WageredEUR: 300, Deposits: 1, DepositsEUR: 1000, Wins: 1, WonEUR: 600}},
+	}
+
+	for _, tt := range tests {
+		got, ok := rows[tt.key]
+		if !ok {
+			t.Errorf("Missing row %+v", tt.key)
+			continue
+		}
+		if *got != tt.expected {
+			t.Errorf("Row %+v = %+v, want %+v", tt.key, *got, tt.expected)
+		}
+	}
+
+	if _, ok := rows[Key{Table: "game_rollups", Period: "day", Bucket: day, ID: 0}]; ok {
+		t.Errorf("Expected deposits without a game to be left out of game rollups")
+	}
+}
+
+func TestSinkDropsFailedEvents(t *testing.T) {
+	// Nothing listens on port 1, so every write fails.
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 sslmode=disable connect_timeout=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink := &Sink{db: db}
+	defer sink.Close()
+	dropped := metrics.EventsDropped.Value("rollup")
+
+	for i := 1; i <= maxBuffered+2; i++ {
+		sink.Add(casino.Event{ID: i})
+	}
+	if len(sink.buffer) != maxBuffered || sink.buffer[0].ID != 3 {
+		t.Fatalf("Expected the %d newest events to be buffered, got %d from event %d", maxBuffered, len(sink.buffer), sink.buffer[0].ID)
+	}
+
+	for i := 0; i < maxAttempts; i++ {
+		if err := sink.Flush(context.Background()); err == nil {
+			t.Fatal("Expected flush to fail")
+		}
+		if i < maxAttempts-1 && len(sink.buffer) != maxBuffered {
+			t.Fatalf("Expected the events to be kept after %d attempts, got %d", i+1, len(sink.buffer))
+		}
+	}
+	if len(sink.buffer) != 0 {
+		t.Errorf("Expected the buffer to be dropped after %d attempts, got %d events", maxAttempts, len(sink.buffer))
+	}
+	if got := metrics.EventsDropped.Value("rollup") - dropped; got != maxBuffered+2 {
+		t.Errorf("Expected %d dropped events, got %v", maxBuffered+2, got)
+	}
+}
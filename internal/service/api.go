@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/bonus"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/fraud"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/health"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/ledger"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/limits"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/materialize"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// API serves the materialized data of a processor running in another
+// process. It follows the snapshots the processor writes to the shared
+// snapshot store and reads wallets from the shared ledger store.
+type API struct {
+	Materializer *materialize.Materialize
+	Ledger       *ledger.Ledger
+	Alerts       fraud.Store
+	Limits       *limits.Checker
+	Bonuses      *bonus.Tracker
+
+	snapshotStore   materialize.SnapshotStore
+	refreshInterval time.Duration
+	healthTimeout   time.Duration
+	databaseCheck   health.Check
+	adminToken      string
+	closers         []io.Closer
+}
+
+// NewAPI creates a new API from the configuration.
+func NewAPI(cfg config.Config) (*API, error) {
+	if cfg.SnapshotStore != "postgres" {
+		return nil, fmt.Errorf("SNAPSHOT_STORE=%q can't be shared with the processor, use postgres", cfg.SnapshotStore)
+	}
+	if cfg.LedgerStore == "memory" {
+		return nil, fmt.Errorf("LEDGER_STORE=memory can't be shared with the processor, use postgres")
+	}
+	if cfg.FraudEnabled && cfg.FraudStore != "postgres" {
+		return nil, fmt.Errorf("FRAUD_STORE=%s can't be shared with the processor, use postgres", cfg.FraudStore)
+	}
+	if cfg.LimitsEnabled && cfg.LimitsStore != "postgres" {
+		return nil, fmt.Errorf("LIMITS_STORE=%s can't be shared with the processor, use postgres", cfg.LimitsStore)
+	}
+	if cfg.BonusEnabled && cfg.BonusStore != "postgres" {
+		return nil, fmt.Errorf("BONUS_STORE=%s can't be shared with the processor, use postgres", cfg.BonusStore)
+	}
+
+	options, err := materializeOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid materialize configuration: %w", err)
+	}
+	a := &API{
+		Materializer:    materialize.NewMaterializeWithOptions(options),
+		refreshInterval: cfg.APIRefreshInterval,
+		healthTimeout:   cfg.HealthTimeout,
+		adminToken:      cfg.AdminToken,
+	}
+
+	a.snapshotStore, err = newSnapshotStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing snapshot store: %w", err)
+	}
+	if store, ok := a.snapshotStore.(io.Closer); ok {
+		a.closers = append(a.closers, store)
+	}
+	var ledgerCloser io.Closer
+	a.Ledger, ledgerCloser, err = newLedger(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing ledger: %w", err)
+	}
+	if ledgerCloser != nil {
+		a.closers = append(a.closers, ledgerCloser)
+	}
+	if cfg.FraudEnabled {
+		var closer io.Closer
+		a.Alerts, closer, err = newAlertStore(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("error initializing alert store: %w", err)
+		}
+		a.closers = append(a.closers, closer)
+	}
+	if cfg.LimitsEnabled {
+		var closer io.Closer
+		a.Limits, closer, err = newLimitsChecker(cfg)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("error initializing limits: %w", err)
+		}
+		a.closers = append(a.closers, closer)
+	}
+	if cfg.BonusEnabled {
+		var closer io.Closer
+		a.Bonuses, closer, err = newBonusTracker(cfg)
+		if err != nil {
+			a.Close()
+			return nil, fmt.Errorf("error initializing bonuses: %w", err)
+		}
+		a.closers = append(a.closers, closer)
+	}
+	if cfg.SnapshotStore == "postgres" || cfg.LedgerStore == "postgres" || cfg.FraudEnabled || cfg.LimitsEnabled || cfg.BonusEnabled {
+		var db io.Closer
+		a.databaseCheck, db, err = databaseCheck(cfg.DatabaseURL)
+		if err != nil {
+			a.Close()
+			return nil, err
+		}
+		a.closers = append(a.closers, db)
+	}
+	return a, nil
+}
+
+// Health registers the check of the database, if the API reads from it.
+func (a *API) Health(c *health.Checker) {
+	if a.databaseCheck != nil {
+		c.Ready("database", a.databaseCheck)
+	}
+}
+
+// Close releases the database connections of the stores and the health check.
+func (a *API) Close() error {
+	for _, c := range a.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// Routes returns the HTTP resources of the enabled components.
+func (a *API) Routes() Routes {
+	return Routes{Ledger: a.Ledger, Alerts: a.Alerts, Limits: a.Limits, Bonuses: a.Bonuses, AdminToken: a.adminToken}
+}
+
+// Run follows the processor snapshots and serves the HTTP API.
+func (a *API) Run(ctx context.Context) {
+	go a.Materializer.FollowSnapshots(ctx, a.snapshotStore, a.refreshInterval)
+	checker := health.NewChecker(a.healthTimeout)
+	a.Health(checker)
+	Serve(a.Materializer, checker, a.Routes())
+}
+
+// Routes are the HTTP resources of the optional components, nil if disabled.
+// Admin resources are served only if AdminToken is set.
+type Routes struct {
+	Ledger     *ledger.Ledger
+	Alerts     fraud.Store
+	Limits     *limits.Checker
+	Bonuses    *bonus.Tracker
+	AdminToken string
+}
+
+// Serve starts the HTTP server of the materializer, with the health checks
+// and the resources of the enabled components.
+func Serve(m *materialize.Materialize, checker *health.Checker, routes Routes) {
+	checker.Handle(http.DefaultServeMux)
+	if routes.Ledger != nil {
+		m.HandlePlayerResource("balance", routes.Ledger.ServeBalance)
+		m.HandlePlayerResource("ledger", routes.Ledger.ServeHistory)
+	}
+	if routes.Alerts != nil {
+		http.HandleFunc("/alerts", fraud.ServeAlerts(routes.Alerts))
+		m.HandlePlayerResource("alerts", fraud.ServePlayerAlerts(routes.Alerts))
+	}
+	if routes.Limits != nil {
+		m.HandlePlayerResource("limits", routes.Limits.ServeLimits)
+		if routes.AdminToken != "" {
+			http.HandleFunc("/admin/players/", adminPlayerResource(routes.AdminToken, "limits", routes.Limits.ServeUpdate))
+		} else {
+			log.Warn().Msg("ADMIN_TOKEN is not set, limits can't be changed over HTTP")
+		}
+	}
+	if routes.Bonuses != nil {
+		m.HandlePlayerResource("bonuses", routes.Bonuses.ServeBonuses)
+	}
+	m.StartHTTPServer()
+}
+
+// adminPlayerResource serves the handler at /admin/players/{id}/{resource}
+// to requests with the admin token as bearer token.
+func adminPlayerResource(token, resource string, handler materialize.PlayerHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		given := strings.TrimPrefix(header, "Bearer ")
+		if !strings.HasPrefix(header, "Bearer ") || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 4 || parts[1] != "players" || parts[3] != resource {
+			http.NotFound(w, r)
+			return
+		}
+		id, err := strconv.Atoi(parts[2])
+		if err != nil {
+			http.Error(w, "invalid player id", http.StatusBadRequest)
+			return
+		}
+		handler(w, r, id)
+	}
+}
+
+// ServeMetrics serves the health checks, the pipeline metrics and the given
+// collectors on addr, for processes without the API.
+func ServeMetrics(addr string, checker *health.Checker, collectors ...metrics.Collector) {
+	mux := http.NewServeMux()
+	checker.Handle(mux)
+	mux.HandleFunc("/metrics", metrics.Handler(collectors...))
+	log.Info().Msgf("Metrics available at http://localhost%s/metrics", addr)
+	if err := http.ListenAndServe(addr, mux); err != nil {
+		log.Error().Err(err).Msg("Failed to serve metrics")
+	}
+}
@@ -0,0 +1,38 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/health"
+	_ "github.com/lib/pq" // PostgreSQL driver
+)
+
+// databaseCheck returns a check pinging the database, and the connection
+// pool it uses, which must be closed.
+func databaseCheck(databaseURL string) (health.Check, io.Closer, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+	return func(ctx context.Context) (string, error) {
+		return "", db.PingContext(ctx)
+	}, db, nil
+}
+
+// ageCheck fails if the time is older than maxAge. The zero time counts as
+// nothing having happened yet, which isn't a failure.
+func ageCheck(what string, at time.Time, maxAge time.Duration) (string, error) {
+	if at.IsZero() {
+		return fmt.Sprintf("no %s yet", what), nil
+	}
+	age := time.Since(at).Round(time.Millisecond)
+	if age > maxAge {
+		return "", fmt.Errorf("last %s was %s ago, more than %s", what, age, maxAge)
+	}
+	return fmt.Sprintf("last %s %s ago", what, age), nil
+}
@@ -0,0 +1,562 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/bonus"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/fraud"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/health"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/ledger"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/limits"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/materialize"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/pubsub"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/rollup"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/store"
+	"github.com/rs/zerolog/log"
+)
+
+// Processor consumes events from the broker and applies them to the
+// materializer, rollups, event store and ledger.
+type Processor struct {
+	Materializer *materialize.Materialize
+	Ledger       *ledger.Ledger
+	Alerts       fraud.Store
+	Limits       *limits.Checker
+	Bonuses      *bonus.Tracker
+
+	snapshotStore materialize.SnapshotStore
+	rollupSink    *rollup.Sink
+	eventWriter   *store.BatchWriter
+	subscriber    *pubsub.Subscriber
+	fraud         *fraud.Engine
+	alerts        *pubsub.Publisher
+	breaches      *pubsub.Publisher
+	bonusEvents   *pubsub.Publisher
+	databaseCheck health.Check
+	maxLag        time.Duration
+	closers       []io.Closer
+	background    sync.WaitGroup
+	adminToken    string
+}
+
+// NewProcessor creates a new Processor from the configuration. It restores
+// the last snapshot and starts the background work of the enabled sinks,
+// which runs until the context is done. With the postgres snapshot store,
+// only one processor may run at a time.
+func NewProcessor(ctx context.Context, cfg config.Config) (*Processor, error) {
+	p := &Processor{
+		subscriber: pubsub.NewSubscriber(cfg.RabbitMQURL),
+		maxLag:     cfg.HealthMaxConsumerLag,
+		adminToken: cfg.AdminToken,
+	}
+
+	// Create an instance of Materializer
+	options, err := materializeOptions(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid materialize configuration: %w", err)
+	}
+	p.Materializer = materialize.NewMaterializeWithOptions(options)
+
+	// Restore materialized state and keep snapshotting it, if enabled
+	p.snapshotStore, err = newSnapshotStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing snapshot store: %w", err)
+	}
+	if store, ok := p.snapshotStore.(*materialize.PostgresSnapshotStore); ok {
+		p.closers = append(p.closers, store)
+		// Replicas would overwrite each other's partial state in the shared snapshot
+		if err := store.Lock(ctx); err != nil {
+			p.Close()
+			return nil, fmt.Errorf("error claiming the snapshot, is another processor running? %w", err)
+		}
+	}
+	if p.snapshotStore != nil {
+		err = p.Materializer.RestoreSnapshot(p.snapshotStore)
+		if errors.Is(err, materialize.ErrNoSnapshot) {
+			log.Info().Msg("No snapshot found, starting with empty state")
+		} else if err != nil {
+			log.Error().Err(err).Msg("Failed to restore snapshot, starting with empty state")
+		} else {
+			log.Info().Msgf("Restored snapshot of %d events up to event %d", p.Materializer.EventsTotal(), p.Materializer.LastEventID())
+		}
+		p.goBackground(func() { p.Materializer.RunSnapshots(ctx, p.snapshotStore, cfg.SnapshotInterval) })
+	}
+
+	// Persist hourly and daily rollups, if enabled
+	if cfg.RollupEnabled {
+		p.rollupSink, err = rollup.NewSink(cfg.DatabaseURL)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("error initializing rollup sink: %w", err)
+		}
+		p.closers = append(p.closers, p.rollupSink)
+		p.goBackground(func() { p.rollupSink.Run(ctx, cfg.RollupInterval) })
+	}
+
+	// Persist every processed event, if enabled
+	var eventRepo *store.EventRepository
+	if cfg.StoreEnabled {
+		eventRepo, err = store.NewEventRepository(cfg.DatabaseURL)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("error initializing event store: %w", err)
+		}
+		p.closers = append(p.closers, eventRepo)
+		p.eventWriter = store.NewBatchWriter(eventRepo, cfg.StoreBatchSize)
+		p.goBackground(func() { p.eventWriter.Run(ctx, cfg.StoreFlushInterval) })
+	}
+
+	// Track player wallets, if enabled
+	var ledgerCloser io.Closer
+	p.Ledger, ledgerCloser, err = newLedger(cfg)
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("error initializing ledger: %w", err)
+	}
+	if ledgerCloser != nil {
+		p.closers = append(p.closers, ledgerCloser)
+	}
+
+	// Raise fraud alerts, if enabled
+	if cfg.FraudEnabled {
+		var closer io.Closer
+		p.Alerts, closer, err = newAlertStore(cfg)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("error initializing alert store: %w", err)
+		}
+		if closer != nil {
+			p.closers = append(p.closers, closer)
+		}
+		p.fraud = fraud.NewEngine(fraudRules(cfg))
+		if eventRepo != nil {
+			if err := rebuildFraud(ctx, p.fraud, eventRepo); err != nil {
+				p.Close()
+				return nil, err
+			}
+		} else {
+			log.Warn().Msg("STORE_ENABLED is off, fraud rule windows start empty")
+		}
+		p.alerts = pubsub.NewQueuePublisher(cfg.RabbitMQURL, fraud.Queue)
+		p.closers = append(p.closers, p.alerts)
+	}
+
+	// Enforce responsible gambling limits, if enabled
+	if cfg.LimitsEnabled {
+		var closer io.Closer
+		p.Limits, closer, err = newLimitsChecker(cfg)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("error initializing limits: %w", err)
+		}
+		if closer != nil {
+			p.closers = append(p.closers, closer)
+		}
+		p.breaches = pubsub.NewQueuePublisher(cfg.RabbitMQURL, limits.Queue)
+		p.closers = append(p.closers, p.breaches)
+	}
+
+	// Track bonuses and their wagering, if enabled
+	if cfg.BonusEnabled {
+		var closer io.Closer
+		p.Bonuses, closer, err = newBonusTracker(cfg)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("error initializing bonuses: %w", err)
+		}
+		if closer != nil {
+			p.closers = append(p.closers, closer)
+		}
+		p.bonusEvents = pubsub.NewQueuePublisher(cfg.RabbitMQURL, bonus.Queue)
+		p.closers = append(p.closers, p.bonusEvents)
+		p.goBackground(func() { p.expireBonuses(ctx, cfg.BonusExpiryInterval) })
+	}
+
+	// Check the database, if any sink uses it
+	if usesDatabase(cfg) {
+		check, db, err := databaseCheck(cfg.DatabaseURL)
+		if err != nil {
+			p.Close()
+			return nil, err
+		}
+		p.databaseCheck = check
+		p.closers = append(p.closers, db)
+	}
+
+	return p, nil
+}
+
+// Health registers the checks of the broker connection and consumer lag, and
+// of the database if any sink uses it.
+func (p *Processor) Health(c *health.Checker) {
+	c.Live("consumer", func(ctx context.Context) (string, error) {
+		if !p.subscriber.Connected() {
+			return "", fmt.Errorf("not connected to RabbitMQ")
+		}
+		return "connected", nil
+	})
+	// A quiet generator leaves the queue empty, which isn't a lag. The lag
+	// is how long events have been waiting without any being consumed.
+	c.Ready("consumer_lag", func(ctx context.Context) (string, error) {
+		pending, err := p.subscriber.Pending()
+		if err != nil {
+			return "", err
+		}
+		if pending == 0 {
+			return "queue empty", nil
+		}
+		idle := time.Since(p.subscriber.LastConsumed()).Round(time.Millisecond)
+		if idle > p.maxLag {
+			return "", fmt.Errorf("%d events queued, nothing consumed for %s, more than %s", pending, idle, p.maxLag)
+		}
+		return fmt.Sprintf("%d events queued, last consumed %s ago", pending, idle), nil
+	})
+	if p.databaseCheck != nil {
+		c.Ready("database", p.databaseCheck)
+	}
+}
+
+// Close releases the database and broker connections of the enabled sinks.
+func (p *Processor) Close() error {
+	for _, c := range p.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// Run subscribes to the broker and processes events as they arrive, until the context is done.
+func (p *Processor) Run(ctx context.Context) error {
+	return p.subscriber.SubscribeEvents(ctx, p.Handle)
+}
+
+// Wait blocks until the background work has finished after the context passed
+// to NewProcessor is done, i.e. the final snapshot is saved and buffered
+// rollups and events are flushed. Call it before Close.
+func (p *Processor) Wait() {
+	p.background.Wait()
+}
+
+// goBackground runs f in a goroutine tracked by Wait.
+func (p *Processor) goBackground(f func()) {
+	p.background.Add(1)
+	go func() {
+		defer p.background.Done()
+		f()
+	}()
+}
+
+// Handle applies a consumed event to the materializer and the enabled sinks.
+func (p *Processor) Handle(event casino.Event) {
+	// Check responsible gambling limits first, so the sinks see the breach
+	if p.Limits != nil {
+		event = p.checkLimits(event)
+	}
+
+	// Update materialized stats
+	p.Materializer.UpdateStats(event)
+
+	// Buffer the event for persisted rollups
+	if p.rollupSink != nil {
+		p.rollupSink.Add(event)
+	}
+
+	// Store the event
+	if p.eventWriter != nil {
+		if err := p.eventWriter.Add(context.Background(), event); err != nil {
+			log.Error().Err(err).Msg("Failed to store events")
+		}
+	}
+
+	// Apply the event to the player wallet
+	if p.Ledger != nil {
+		_, err := p.Ledger.Apply(context.Background(), event)
+		if errors.Is(err, ledger.ErrInsufficientFunds) {
+			log.Warn().Err(err).Int("player_id", event.PlayerID).Msg("Rejected bet")
+		} else if err != nil && !errors.Is(err, ledger.ErrDuplicate) {
+			log.Error().Err(err).Msg("Failed to apply event to ledger")
+		}
+	}
+
+	// Raise and publish fraud alerts
+	if p.fraud != nil {
+		p.raiseAlerts(event)
+	}
+
+	// Track bonus wagering and publish bonus events
+	if p.Bonuses != nil {
+		p.trackBonuses(event)
+	}
+
+	eventJSON, _ := json.Marshal(event)
+	log.Info().Msgf("Processed Event: %s", string(eventJSON))
+}
+
+// checkLimits counts the event towards the limits of its player, marks it
+// if it breaches any and publishes the first breach of each limit and
+// period to the breaches queue.
+func (p *Processor) checkLimits(event casino.Event) casino.Event {
+	breaches, err := p.Limits.Check(context.Background(), event)
+	if errors.Is(err, limits.ErrDuplicate) {
+		return event
+	} else if err != nil {
+		log.Error().Err(err).Msg("Failed to check limits")
+		return event
+	}
+
+	event.LimitBreached = len(breaches) > 0
+	for _, breach := range breaches {
+		err := p.Limits.Record(context.Background(), breach)
+		if errors.Is(err, limits.ErrDuplicate) {
+			continue
+		} else if err != nil {
+			log.Error().Err(err).Msg("Failed to record limit breach")
+			continue
+		}
+
+		log.Warn().Str("type", breach.Type).Int("player_id", breach.PlayerID).Str("kind", breach.Kind).
+			Str("period", string(breach.Period)).Msgf("Player %d breached a limit with event %d", breach.PlayerID, breach.EventID)
+		if err := p.breaches.PublishJSON(breach.Type, breach); err != nil {
+			log.Error().Err(err).Msg("Failed to publish limit breach")
+		}
+	}
+	return event
+}
+
+// expireBonuses expires the bonuses past their expiry every interval until
+// the context is done, and publishes the bonus events to the bonuses queue.
+func (p *Processor) expireBonuses(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		events, err := p.Bonuses.Expire(ctx, time.Now())
+		if err != nil {
+			log.Error().Err(err).Msg("Failed to expire bonuses")
+			continue
+		}
+		p.publishBonusEvents(events)
+	}
+}
+
+// trackBonuses applies the event to the bonuses of its player and publishes
+// the resulting bonus events to the bonuses queue.
+func (p *Processor) trackBonuses(event casino.Event) {
+	events, err := p.Bonuses.Track(context.Background(), event)
+	if errors.Is(err, bonus.ErrDuplicate) {
+		return
+	} else if err != nil {
+		log.Error().Err(err).Msg("Failed to track bonuses")
+	}
+	p.publishBonusEvents(events)
+}
+
+// publishBonusEvents logs the bonus events and publishes them to the bonuses queue.
+func (p *Processor) publishBonusEvents(events []bonus.Event) {
+	for _, e := range events {
+		log.Info().Str("type", e.Type).Int("player_id", e.Bonus.PlayerID).Str("campaign", e.Bonus.CampaignID).
+			Msgf("Player %d bonus %d: %s", e.Bonus.PlayerID, e.Bonus.ID, e.Bonus.Status)
+		if err := p.bonusEvents.PublishJSON(e.Type, e); err != nil {
+			log.Error().Err(err).Msg("Failed to publish bonus event")
+		}
+	}
+}
+
+// rebuildFraud replays the stored events within the horizon of the engine,
+// so its windows survive a restart. The alerts they raise were already
+// raised before and are dropped.
+func rebuildFraud(ctx context.Context, engine *fraud.Engine, events *store.EventRepository) error {
+	var count int
+	err := events.ReadEvents(ctx, time.Now().Add(-engine.Horizon()), time.Time{}, func(event casino.Event) error {
+		engine.Evaluate(event)
+		count++
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("error rebuilding fraud rule windows: %w", err)
+	}
+	log.Info().Msgf("Rebuilt fraud rule windows from %d stored events", count)
+	return nil
+}
+
+// raiseAlerts evaluates the fraud rules, stores the raised alerts and
+// publishes them to the alerts queue.
+func (p *Processor) raiseAlerts(event casino.Event) {
+	for _, alert := range p.fraud.Evaluate(event) {
+		alert, err := p.Alerts.Add(context.Background(), alert)
+		if errors.Is(err, fraud.ErrDuplicate) {
+			continue
+		} else if err != nil {
+			log.Error().Err(err).Msg("Failed to store alert")
+			continue
+		}
+
+		log.Warn().Str("rule", alert.Rule).Int("player_id", alert.PlayerID).Msg(alert.Message)
+		if err := p.alerts.PublishJSON("alert", alert); err != nil {
+			log.Error().Err(err).Msg("Failed to publish alert")
+		}
+	}
+}
+
+// Routes returns the HTTP resources of the enabled components.
+func (p *Processor) Routes() Routes {
+	return Routes{Ledger: p.Ledger, Alerts: p.Alerts, Limits: p.Limits, Bonuses: p.Bonuses, AdminToken: p.adminToken}
+}
+
+// materializeOptions builds the materializer options from the configuration
+func materializeOptions(cfg config.Config) (materialize.Options, error) {
+	options := materialize.DefaultOptions()
+	options.HeavyHittersError = cfg.HeavyHittersError
+	options.UniquePlayersError = cfg.UniquePlayersError
+	options.MaxPlayerProfiles = cfg.MaxPlayerProfiles
+	options.Addr = cfg.HTTPAddr
+	options.StreamInterval = cfg.StreamInterval
+
+	modes := []struct {
+		value string
+		mode  *materialize.Mode
+	}{
+		{cfg.TopPlayerBetsMode, &options.TopPlayerBets},
+		{cfg.TopPlayerWinsMode, &options.TopPlayerWins},
+		{cfg.TopPlayerDepositsMode, &options.TopPlayerDeposits},
+		{cfg.UniquePlayersMode, &options.UniquePlayers},
+	}
+	for _, m := range modes {
+		mode, err := materialize.ParseMode(m.value)
+		if err != nil {
+			return options, err
+		}
+		*m.mode = mode
+	}
+	return options, nil
+}
+
+// newSnapshotStore creates the configured snapshot store, or nil if snapshots are disabled
+func newSnapshotStore(cfg config.Config) (materialize.SnapshotStore, error) {
+	switch cfg.SnapshotStore {
+	case "":
+		return nil, nil
+	case "file":
+		return materialize.NewFileSnapshotStore(cfg.SnapshotPath), nil
+	case "postgres":
+		return materialize.NewPostgresSnapshotStore(cfg.DatabaseURL, "default")
+	default:
+		return nil, fmt.Errorf("unknown snapshot store %q", cfg.SnapshotStore)
+	}
+}
+
+// newAlertStore creates the configured fraud alert store, and the connection
+// to close if it has one
+func newAlertStore(cfg config.Config) (fraud.Store, io.Closer, error) {
+	switch cfg.FraudStore {
+	case "memory":
+		return fraud.NewMemoryStore(), nil, nil
+	case "postgres":
+		store, err := fraud.NewPostgresStore(cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return store, store, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown fraud store %q", cfg.FraudStore)
+	}
+}
+
+// newLimitsChecker creates the limits checker with the configured store, and
+// the connection to close if it has one
+func newLimitsChecker(cfg config.Config) (*limits.Checker, io.Closer, error) {
+	switch cfg.LimitsStore {
+	case "memory":
+		return limits.NewChecker(limits.NewMemoryStore(), cfg.LimitsCoolingOff), nil, nil
+	case "postgres":
+		store, err := limits.NewPostgresStore(cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return limits.NewChecker(store, cfg.LimitsCoolingOff), store, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown limits store %q", cfg.LimitsStore)
+	}
+}
+
+// newBonusTracker creates the bonus tracker with the configured campaigns
+// and store, and the connection to close if it has one
+func newBonusTracker(cfg config.Config) (*bonus.Tracker, io.Closer, error) {
+	campaigns, err := bonus.LoadCampaigns(cfg.BonusCampaigns)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch cfg.BonusStore {
+	case "memory":
+		return bonus.NewTracker(bonus.NewMemoryStore(), campaigns), nil, nil
+	case "postgres":
+		store, err := bonus.NewPostgresStore(cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return bonus.NewTracker(store, campaigns), store, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown bonus store %q", cfg.BonusStore)
+	}
+}
+
+// usesDatabase reports whether any enabled component of the processor uses Postgres
+func usesDatabase(cfg config.Config) bool {
+	return cfg.RollupEnabled || cfg.StoreEnabled || cfg.SnapshotStore == "postgres" || cfg.LedgerStore == "postgres" ||
+		(cfg.FraudEnabled && cfg.FraudStore == "postgres") || (cfg.LimitsEnabled && cfg.LimitsStore == "postgres") ||
+		(cfg.BonusEnabled && cfg.BonusStore == "postgres")
+}
+
+// fraudRules builds the fraud rules from the configuration
+func fraudRules(cfg config.Config) fraud.Rules {
+	return fraud.Rules{
+		MaxDeposits:    cfg.FraudMaxDeposits,
+		DepositWindow:  cfg.FraudDepositWindow,
+		BetJumpFactor:  cfg.FraudBetJumpFactor,
+		BetJumpMinBets: cfg.FraudBetJumpMinBets,
+		MaxCurrencies:  cfg.FraudMaxCurrencies,
+		CurrencyWindow: cfg.FraudCurrencyWindow,
+		RTPMargin:      cfg.FraudRTPMargin,
+		RTPMinBets:     cfg.FraudRTPMinBets,
+		RTPWindow:      cfg.FraudRTPWindow,
+		Cooldown:       cfg.FraudAlertCooldown,
+	}
+}
+
+// newLedger creates the wallet ledger with the configured store, or nil if the
+// ledger is disabled, and the connection to close if it has one
+func newLedger(cfg config.Config) (*ledger.Ledger, io.Closer, error) {
+	policy, err := ledger.ParsePolicy(cfg.LedgerOverdrawPolicy)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch cfg.LedgerStore {
+	case "":
+		return nil, nil, nil
+	case "memory":
+		return ledger.NewLedger(ledger.NewMemoryStore(), policy), nil, nil
+	case "postgres":
+		ledgerStore, err := ledger.NewPostgresStore(cfg.DatabaseURL)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ledger.NewLedger(ledgerStore, policy), ledgerStore, nil
+	default:
+		return nil, nil, fmt.Errorf("unknown ledger store %q", cfg.LedgerStore)
+	}
+}
@@ -0,0 +1,302 @@
+// Package service This wires the internal packages into the producer, processor and API services.
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	config "github.com/Bitstarz-eng/event-processing-challenge/internal"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/breaker"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/enrichment"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/generator"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/health"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/pubsub"
+	"github.com/rs/zerolog/log"
+)
+
+// Producer generates events, enriches them and publishes them to the broker.
+type Producer struct {
+	playerRepo *enrichment.PlayerRepository
+	rates      *enrichment.ExchangeRates
+	publisher  *pubsub.Publisher
+	generator  *generator.Generator
+	chaos      *generator.Chaos
+	manifest   *os.File
+	maxRateAge time.Duration
+	running    int32
+}
+
+// NewProducer creates a new Producer from the configuration.
+func NewProducer(ctx context.Context, cfg config.Config) (*Producer, error) {
+	// Initialize the database connection pool
+	if cfg.BreakerThreshold < 1 {
+		return nil, fmt.Errorf("BREAKER_THRESHOLD must be at least 1")
+	}
+	playerBreaker := breaker.New("player_db", cfg.BreakerThreshold, cfg.BreakerOpenTimeout)
+	playerRepo, err := enrichment.NewPlayerRepository(cfg.DatabaseURL, cfg.PlayerLookupTimeout, playerBreaker)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing player repository: %w", err)
+	}
+
+	// Refresh exchange rates in the background
+	rateBreaker := breaker.New("exchange_rates", cfg.BreakerThreshold, cfg.BreakerOpenTimeout)
+	rates := enrichment.NewExchangeRates(cfg.ExchangeRateAPI, cfg.ExchangeRateTimeout, rateBreaker)
+	go rates.Run(ctx, cfg.ExchangeRateRefresh)
+
+	p := &Producer{
+		playerRepo: playerRepo,
+		rates:      rates,
+		publisher:  pubsub.NewPublisher(cfg.RabbitMQURL),
+		maxRateAge: cfg.HealthMaxRateAge,
+	}
+
+	options, err := generatorOptions(cfg)
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("invalid generator configuration: %w", err)
+	}
+	p.generator, err = newGenerator(ctx, cfg, options)
+	if err != nil {
+		p.Close()
+		return nil, fmt.Errorf("error setting up generator players: %w", err)
+	}
+	log.Info().Msgf("Generator seed: %d", p.generator.Seed())
+
+	// Inject faults into the published events, if enabled
+	if cfg.ChaosFaults != "" {
+		rates, err := generator.ParseFaultRates(cfg.ChaosFaults)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("invalid chaos configuration: %w", err)
+		}
+		p.manifest, err = os.Create(cfg.ChaosManifest)
+		if err != nil {
+			p.Close()
+			return nil, fmt.Errorf("error creating chaos manifest: %w", err)
+		}
+		p.chaos = generator.NewChaos(rates, p.generator.Seed(), p.manifest)
+		log.Warn().Msgf("Chaos mode enabled, recording injected faults in %s", cfg.ChaosManifest)
+	}
+
+	return p, nil
+}
+
+// Close releases the database and broker connections and the chaos manifest.
+func (p *Producer) Close() error {
+	if p.manifest != nil {
+		p.manifest.Close()
+	}
+	p.publisher.Close()
+	return p.playerRepo.Close()
+}
+
+// Run publishes generated events, injecting faults if chaos mode is enabled,
+// until the context is done or the generator stops.
+func (p *Producer) Run(ctx context.Context) {
+	atomic.StoreInt32(&p.running, 1)
+	defer atomic.StoreInt32(&p.running, 0)
+
+	for event := range p.generator.Generate(ctx) {
+		messages := []generator.Message{{Event: event}}
+		if p.chaos != nil {
+			var err error
+			messages, err = p.chaos.Apply(event)
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to record injected fault")
+			}
+		}
+
+		for _, message := range messages {
+			// Malformed messages skip enrichment and are published as they are
+			if message.Raw != nil {
+				if err := p.publisher.PublishRaw(message.Raw); err != nil {
+					log.Error().Err(err).Msg("Failed to publish raw message")
+				}
+				continue
+			}
+
+			// Publish event to RabbitMQ
+			err := p.publisher.PublishEvent(p.enrich(ctx, message.Event))
+			if err != nil {
+				log.Error().Err(err).Msg("Failed to publish event")
+			}
+		}
+	}
+}
+
+// Health registers the checks of the generator, broker, database and
+// exchange rates.
+func (p *Producer) Health(c *health.Checker) {
+	c.Live("generator", func(ctx context.Context) (string, error) {
+		if atomic.LoadInt32(&p.running) == 0 {
+			return "", fmt.Errorf("generator is not running")
+		}
+		return "running", nil
+	})
+	c.Ready("broker", func(ctx context.Context) (string, error) {
+		return "", p.publisher.Ping()
+	})
+	c.Ready("player_database", func(ctx context.Context) (string, error) {
+		return "", p.playerRepo.Ping(ctx)
+	})
+	c.Ready("exchange_rates", func(ctx context.Context) (string, error) {
+		// Events can still be converted with the fallback rates, so they
+		// don't make the producer unready, but the detail tells them apart
+		updated := p.rates.Updated()
+		if updated.IsZero() {
+			return "no rates fetched yet, using fallback rates", nil
+		}
+		return ageCheck("update", updated, p.maxRateAge)
+	})
+}
+
+// enrich adds the EUR amounts, player data and description to the event
+func (p *Producer) enrich(ctx context.Context, event casino.Event) casino.Event {
+	// Convert amount to EUR
+	start := time.Now()
+	if event.Currency != "" {
+		event.AmountEUR = p.rates.ConvertToEUR(event.Amount, event.Currency)
+	}
+	if event.WinAmount > 0 {
+		event.WinAmountEUR = p.rates.ConvertToEUR(event.WinAmount, event.Currency)
+	}
+	metrics.EnrichmentDuration.Observe(time.Since(start).Seconds(), "currency")
+
+	// Fetch player data using the shared DB connection
+	start = time.Now()
+	player, err := p.playerRepo.FetchPlayer(ctx, event.PlayerID)
+	metrics.EnrichmentDuration.Observe(time.Since(start).Seconds(), "player")
+	if errors.Is(err, breaker.ErrOpen) {
+		log.Warn().Msgf("Skipped fetching player %d, the player database breaker is open", event.PlayerID)
+	} else if err != nil {
+		log.Error().Err(err).Msgf("Failed to fetch player %d", event.PlayerID)
+	} else {
+		event.Player = player
+	}
+
+	// Check if player data is missing using IsZero()
+	if event.Player.IsZero() {
+		log.Warn().Msgf("Player %d not found, leaving player field empty.", event.PlayerID)
+	}
+
+	// Generate human-friendly description
+	start = time.Now()
+	event.Description = enrichment.GenerateDescription(event)
+	metrics.EnrichmentDuration.Observe(time.Since(start).Seconds(), "description")
+
+	return event
+}
+
+// newGenerator creates the generator with the configured player population:
+// simulated players only, players loaded from Postgres, or simulated players
+// seeded into Postgres so enrichment finds them
+func newGenerator(ctx context.Context, cfg config.Config, options generator.Options) (*generator.Generator, error) {
+	switch cfg.GeneratorPopulation {
+	case "simulated":
+		return generator.New(options), nil
+	case "postgres", "seed":
+	default:
+		return nil, fmt.Errorf("unknown generator population %q", cfg.GeneratorPopulation)
+	}
+
+	population, err := generator.NewPopulationRepository(cfg.DatabaseURL)
+	if err != nil {
+		return nil, err
+	}
+	defer population.Close()
+
+	if cfg.GeneratorPopulation == "postgres" {
+		options.Population, err = population.LoadPopulation(ctx)
+		if err != nil {
+			return nil, err
+		}
+		log.Info().Msgf("Loaded %d players from the database", len(options.Population))
+		return generator.New(options), nil
+	}
+
+	gen := generator.New(options)
+	seeded, err := population.SeedPlayers(ctx, gen.Population())
+	if err != nil {
+		return nil, err
+	}
+	log.Info().Msgf("Seeded %d missing players into the database", seeded)
+	return gen, nil
+}
+
+// generatorOptions builds the generator options from the configuration
+func generatorOptions(cfg config.Config) (generator.Options, error) {
+	options := generator.DefaultOptions()
+	if cfg.GeneratorPlayers < 1 {
+		return options, fmt.Errorf("GENERATOR_PLAYERS must be at least 1")
+	}
+	options.Players = cfg.GeneratorPlayers
+	options.Seed = int64(cfg.GeneratorSeed)
+	options.WhaleShare = cfg.GeneratorWhaleShare
+	options.WhaleWeight = cfg.GeneratorWhaleWeight
+	options.WhaleScale = cfg.GeneratorWhaleScale
+	if options.WhaleShare < 0 || options.WhaleShare > 1 || options.WhaleWeight <= 0 || options.WhaleScale <= 0 {
+		return options, fmt.Errorf("GENERATOR_WHALE_SHARE must be between 0 and 1, GENERATOR_WHALE_WEIGHT and GENERATOR_WHALE_SCALE positive")
+	}
+
+	if cfg.GeneratorGames != "" {
+		options.Games = nil
+		for _, s := range strings.Split(cfg.GeneratorGames, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(s))
+			if err != nil {
+				return options, fmt.Errorf("invalid game ID %q in GENERATOR_GAMES", s)
+			}
+			if _, ok := casino.Games[id]; !ok {
+				return options, fmt.Errorf("unknown game ID %d in GENERATOR_GAMES", id)
+			}
+			options.Games = append(options.Games, id)
+		}
+	}
+
+	if cfg.GeneratorCurrencies != "" {
+		options.Currencies = nil
+		for _, s := range strings.Split(cfg.GeneratorCurrencies, ",") {
+			currency := strings.ToUpper(strings.TrimSpace(s))
+			if !casino.IsCurrency(currency) {
+				return options, fmt.Errorf("unknown currency %q in GENERATOR_CURRENCIES", s)
+			}
+			options.Currencies = append(options.Currencies, currency)
+		}
+	}
+
+	var err error
+	if options.BetSize, err = generator.ParseDistribution(cfg.GeneratorBetSize); err != nil {
+		return options, err
+	}
+	if options.DepositSize, err = generator.ParseDistribution(cfg.GeneratorDepositSize); err != nil {
+		return options, err
+	}
+	if options.Profile, err = generator.ParseProfile(cfg.GeneratorProfile); err != nil {
+		return options, err
+	}
+	if cfg.GeneratorScenario != "" {
+		if options.Scenario, err = generator.LoadScenario(cfg.GeneratorScenario); err != nil {
+			return options, err
+		}
+		log.Info().Msgf("Playing scenario %s", cfg.GeneratorScenario)
+	}
+
+	options.ReportInterval = cfg.GeneratorReportInterval
+	options.Report = func(r generator.RateReport) {
+		metrics.GeneratorTargetRate.Set(r.Target)
+		metrics.GeneratorAchievedRate.Set(r.Achieved)
+		logEvent := log.Info()
+		if r.Achieved < 0.95*r.Target {
+			logEvent = log.Warn()
+		}
+		logEvent.Msgf("Generator rate: target %.1f events/s, achieved %.1f events/s (%d events)", r.Target, r.Achieved, r.Events)
+	}
+	return options, nil
+}
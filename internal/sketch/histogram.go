@@ -0,0 +1,152 @@
+package sketch
+
+import (
+	"math/bits"
+	"sort"
+)
+
+// subBucketBits sets the resolution of a Histogram: each power of two is
+// split into 2^(subBucketBits-1) linear buckets, which bounds the relative
+// error of quantiles to 1/2^(subBucketBits-1), i.e. about 1.6%.
+const subBucketBits = 7
+
+// HistogramBucket is a non-empty bucket of a Histogram covering [From, To].
+type HistogramBucket struct {
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	Count uint64 `json:"count"`
+}
+
+// Histogram is a streaming log-linear histogram of non-negative integers,
+// in the spirit of HDR histograms. Memory grows with the number of distinct
+// buckets in use, not with the number of values recorded.
+type Histogram struct {
+	Counts map[int]uint64 `json:"counts"`
+	Total  uint64         `json:"total"`
+	Sum    int            `json:"sum"`
+	Min    int            `json:"min"`
+	Max    int            `json:"max"`
+}
+
+// NewHistogram creates an empty Histogram.
+func NewHistogram() *Histogram {
+	return &Histogram{Counts: make(map[int]uint64)}
+}
+
+// Record adds a value. Negative values are recorded as zero.
+func (h *Histogram) Record(value int) {
+	if value < 0 {
+		value = 0
+	}
+	if h.Counts == nil {
+		h.Counts = make(map[int]uint64)
+	}
+	if h.Total == 0 || value < h.Min {
+		h.Min = value
+	}
+	if value > h.Max {
+		h.Max = value
+	}
+	h.Counts[bucketIndex(value)]++
+	h.Total++
+	h.Sum += value
+}
+
+// Quantile returns the value at quantile q, between 0 and 1.
+func (h *Histogram) Quantile(q float64) int {
+	if h.Total == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return h.Min
+	}
+	if q >= 1 {
+		return h.Max
+	}
+
+	rank := uint64(q*float64(h.Total) + 0.5)
+	if rank == 0 {
+		rank = 1
+	}
+
+	var seen uint64
+	for _, idx := range h.indexes() {
+		seen += h.Counts[idx]
+		if seen >= rank {
+			from, to := bucketBounds(idx)
+			value := from + (to-from)/2
+			// The exact extremes are known, so never report beyond them.
+			if value > h.Max {
+				value = h.Max
+			}
+			if value < h.Min {
+				value = h.Min
+			}
+			return value
+		}
+	}
+	return h.Max
+}
+
+// Mean returns the arithmetic mean of the recorded values.
+func (h *Histogram) Mean() float64 {
+	if h.Total == 0 {
+		return 0
+	}
+	return float64(h.Sum) / float64(h.Total)
+}
+
+// Buckets returns the non-empty buckets in ascending order.
+func (h *Histogram) Buckets() []HistogramBucket {
+	var buckets []HistogramBucket
+	for _, idx := range h.indexes() {
+		from, to := bucketBounds(idx)
+		buckets = append(buckets, HistogramBucket{From: from, To: to, Count: h.Counts[idx]})
+	}
+	return buckets
+}
+
+// Clone returns a copy of the Histogram.
+func (h *Histogram) Clone() *Histogram {
+	c := *h
+	c.Counts = make(map[int]uint64, len(h.Counts))
+	for k, v := range h.Counts {
+		c.Counts[k] = v
+	}
+	return &c
+}
+
+func (h *Histogram) indexes() []int {
+	indexes := make([]int, 0, len(h.Counts))
+	for idx := range h.Counts {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// bucketIndex maps values below 2^subBucketBits to themselves and larger
+// values to 2^(subBucketBits-1) linear buckets per power of two.
+func bucketIndex(value int) int {
+	v := uint64(value)
+	if v < 1<<subBucketBits {
+		return value
+	}
+	shift := bits.Len64(v) - subBucketBits
+	half := 1 << (subBucketBits - 1)
+	return 1<<subBucketBits + (shift-1)*half + int(v>>shift) - half
+}
+
+// bucketBounds returns the smallest and largest value mapped to the bucket index.
+func bucketBounds(idx int) (from, to int) {
+	if idx < 1<<subBucketBits {
+		return idx, idx
+	}
+	half := 1 << (subBucketBits - 1)
+	rel := idx - 1<<subBucketBits
+	shift := rel/half + 1
+	mantissa := rel%half + half
+	from = mantissa << shift
+	to = from + 1<<shift - 1
+	return from, to
+}
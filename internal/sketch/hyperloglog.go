@@ -0,0 +1,125 @@
+// Package sketch This provides probabilistic data structures for approximate counting with bounded memory.
+package sketch
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// Precision bounds of a HyperLogLog.
+const (
+	MinPrecision = 4
+	MaxPrecision = 18
+)
+
+// HyperLogLog estimates the number of distinct items.
+// Its memory usage is 2^Precision bytes regardless of the number of items.
+type HyperLogLog struct {
+	Precision uint8  `json:"precision"`
+	Registers []byte `json:"registers"`
+}
+
+// NewHyperLogLog creates a HyperLogLog with a standard error of at most stdErr,
+// e.g. 0.01 for 1%.
+func NewHyperLogLog(stdErr float64) *HyperLogLog {
+	return NewHyperLogLogWithPrecision(PrecisionForError(stdErr))
+}
+
+// NewHyperLogLogWithPrecision creates a HyperLogLog with 2^precision registers.
+func NewHyperLogLogWithPrecision(precision uint8) *HyperLogLog {
+	if precision < MinPrecision {
+		precision = MinPrecision
+	}
+	if precision > MaxPrecision {
+		precision = MaxPrecision
+	}
+	return &HyperLogLog{Precision: precision, Registers: make([]byte, 1<<precision)}
+}
+
+// PrecisionForError returns the smallest precision with a standard error of at most stdErr.
+func PrecisionForError(stdErr float64) uint8 {
+	if stdErr <= 0 {
+		return MaxPrecision
+	}
+	p := math.Ceil(math.Log2(math.Pow(1.04/stdErr, 2)))
+	if p < MinPrecision {
+		return MinPrecision
+	}
+	if p > MaxPrecision {
+		return MaxPrecision
+	}
+	return uint8(p)
+}
+
+// Add adds an item.
+func (h *HyperLogLog) Add(item int) {
+	hash := mix(uint64(item))
+	idx := hash >> (64 - h.Precision)
+	rho := uint8(bits.LeadingZeros64(hash<<h.Precision|1<<(h.Precision-1))) + 1
+	if rho > h.Registers[idx] {
+		h.Registers[idx] = rho
+	}
+}
+
+// Count returns the estimated number of distinct items added.
+func (h *HyperLogLog) Count() int {
+	m := float64(len(h.Registers))
+
+	var sum float64
+	var zeros int
+	for _, r := range h.Registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(len(h.Registers)) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+	return int(estimate + 0.5)
+}
+
+// Merge adds all items of other, which must have the same precision.
+func (h *HyperLogLog) Merge(other *HyperLogLog) error {
+	if h.Precision != other.Precision {
+		return errors.New("sketch: cannot merge HyperLogLogs with different precision")
+	}
+	for i, r := range other.Registers {
+		if r > h.Registers[i] {
+			h.Registers[i] = r
+		}
+	}
+	return nil
+}
+
+// Clone returns a copy of the HyperLogLog.
+func (h *HyperLogLog) Clone() *HyperLogLog {
+	return &HyperLogLog{Precision: h.Precision, Registers: append([]byte(nil), h.Registers...)}
+}
+
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// mix spreads the bits of x over the whole 64 bits (splitmix64 finalizer),
+// so sequential IDs hash to unrelated values.
+func mix(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
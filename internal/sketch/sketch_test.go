@@ -0,0 +1,137 @@
+package sketch
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func TestHyperLogLog(t *testing.T) {
+	hll := NewHyperLogLog(0.01)
+	if hll.Precision != 14 {
+		t.Errorf("Expected precision 14 for 1%% error, got %d", hll.Precision)
+	}
+
+	for _, n := range []int{10, 1000, 100000} {
+		hll := NewHyperLogLog(0.01)
+		for i := 0; i < n; i++ {
+			hll.Add(i)
+			hll.Add(i) // Duplicates don't count.
+		}
+
+		got := hll.Count()
+		if relErr := math.Abs(float64(got-n)) / float64(n); relErr > 0.03 {
+			t.Errorf("Count() = %d for %d distinct items, relative error %.4f", got, n, relErr)
+		}
+	}
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+	a := NewHyperLogLog(0.01)
+	b := NewHyperLogLog(0.01)
+	for i := 0; i < 5000; i++ {
+		a.Add(i)
+		b.Add(i + 2500)
+	}
+
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if got := a.Count(); math.Abs(float64(got-7500))/7500 > 0.03 {
+		t.Errorf("Count() after merge = %d, want about 7500", got)
+	}
+
+	if err := a.Merge(NewHyperLogLog(0.1)); err == nil {
+		t.Errorf("Expected error merging HyperLogLogs with different precision")
+	}
+}
+
+func TestSpaceSaving(t *testing.T) {
+	ss := NewSpaceSaving(0.1)
+	if ss.Capacity() != 10 {
+		t.Errorf("Expected capacity 10 for 10%% error, got %d", ss.Capacity())
+	}
+
+	// Two heavy hitters among many light items.
+	total := 0
+	for i := 0; i < 1000; i++ {
+		ss.Add(1, 5)
+		ss.Add(2, 3)
+		ss.Add(100+i, 1)
+		total += 9
+	}
+
+	top := ss.Top(2)
+	if len(top) != 2 || top[0].Item != 1 || top[1].Item != 2 {
+		t.Fatalf("Expected items 1 and 2 as heavy hitters, got %+v", top)
+	}
+	if top[0].Count < 5000 || top[0].Count-5000 > total/ss.Capacity() {
+		t.Errorf("Count of item 1 = %d, want 5000 within error %d", top[0].Count, total/ss.Capacity())
+	}
+
+	data, err := json.Marshal(ss)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded SpaceSaving
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	decoded.Add(2, 10000)
+	if top := decoded.Top(1); top[0].Item != 2 {
+		t.Errorf("Expected item 2 on top after decoding and adding, got %+v", top)
+	}
+	if max, ok := decoded.Max(); !ok || max != decoded.Top(1)[0] {
+		t.Errorf("Expected Max to match Top(1) %+v, got %+v", decoded.Top(1)[0], max)
+	}
+	if c, ok := decoded.Counter(2); !ok || c.Count < 13000 {
+		t.Errorf("Expected counter of item 2 to be at least 13000, got %+v", c)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	h := NewHistogram()
+	for i := 1; i <= 100000; i++ {
+		h.Record(i)
+	}
+
+	tests := []struct {
+		q    float64
+		want int
+	}{
+		{0.5, 50000},
+		{0.9, 90000},
+		{0.99, 99000},
+	}
+	for _, tt := range tests {
+		got := h.Quantile(tt.q)
+		if relErr := math.Abs(float64(got-tt.want)) / float64(tt.want); relErr > 0.016 {
+			t.Errorf("Quantile(%v) = %d, want %d within 1.6%%", tt.q, got, tt.want)
+		}
+	}
+
+	if h.Quantile(1) != 100000 || h.Max != 100000 || h.Min != 1 {
+		t.Errorf("Expected min 1 and max 100000, got min %d, max %d, q1 %d", h.Min, h.Max, h.Quantile(1))
+	}
+
+	var total uint64
+	for i, b := range h.Buckets() {
+		if b.From > b.To {
+			t.Fatalf("Bucket %d has From %d > To %d", i, b.From, b.To)
+		}
+		total += b.Count
+	}
+	if total != h.Total {
+		t.Errorf("Bucket counts sum to %d, want %d", total, h.Total)
+	}
+}
+
+func TestHistogramBucketBounds(t *testing.T) {
+	for v := 0; v < 1<<20; v++ {
+		from, to := bucketBounds(bucketIndex(v))
+		if v < from || v > to {
+			t.Fatalf("Value %d mapped to bucket [%d, %d]", v, from, to)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package sketch
+
+import (
+	"container/heap"
+	"encoding/json"
+	"math"
+	"sort"
+)
+
+// SpaceSavingCounter is a monitored item of a SpaceSaving sketch.
+// Count overestimates the true count by at most Error.
+type SpaceSavingCounter struct {
+	Item  int `json:"item"`
+	Count int `json:"count"`
+	Error int `json:"error"`
+}
+
+// SpaceSaving tracks the heavy hitters of a weighted stream using a fixed
+// number of counters. With capacity k, any item whose total exceeds N/k is
+// guaranteed to be monitored, and counts overestimate by at most N/k, where
+// N is the sum of all weights added.
+type SpaceSaving struct {
+	capacity int
+	counters counterHeap
+	index    map[int]int
+}
+
+// NewSpaceSaving creates a SpaceSaving sketch whose counts are off by at most
+// maxErr times the total weight, e.g. 0.001 for 0.1%.
+func NewSpaceSaving(maxErr float64) *SpaceSaving {
+	capacity := 1
+	if maxErr > 0 && maxErr < 1 {
+		capacity = int(math.Ceil(1 / maxErr))
+	}
+	return NewSpaceSavingWithCapacity(capacity)
+}
+
+// NewSpaceSavingWithCapacity creates a SpaceSaving sketch monitoring at most capacity items.
+func NewSpaceSavingWithCapacity(capacity int) *SpaceSaving {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &SpaceSaving{capacity: capacity, index: make(map[int]int)}
+}
+
+// Add adds weight to the count of item.
+func (s *SpaceSaving) Add(item, weight int) {
+	if i, ok := s.index[item]; ok {
+		s.counters[i].Count += weight
+		s.fix(i)
+		return
+	}
+
+	if len(s.counters) < s.capacity {
+		s.counters = append(s.counters, SpaceSavingCounter{Item: item, Count: weight})
+		s.index[item] = len(s.counters) - 1
+		s.fix(len(s.counters) - 1)
+		return
+	}
+
+	// Replace the item with the smallest count, inheriting its count as error.
+	min := s.counters[0]
+	delete(s.index, min.Item)
+	s.counters[0] = SpaceSavingCounter{Item: item, Count: min.Count + weight, Error: min.Count}
+	s.index[item] = 0
+	s.fix(0)
+}
+
+// Top returns the n items with the highest counts, highest first.
+func (s *SpaceSaving) Top(n int) []SpaceSavingCounter {
+	top := append([]SpaceSavingCounter(nil), s.counters...)
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Item < top[j].Item
+	})
+	if n < len(top) {
+		top = top[:n]
+	}
+	return top
+}
+
+// Max returns the item with the highest count, like Top(1) but without
+// copying the counters, and false if no item was added.
+func (s *SpaceSaving) Max() (SpaceSavingCounter, bool) {
+	if len(s.counters) == 0 {
+		return SpaceSavingCounter{}, false
+	}
+	max := s.counters[0]
+	for _, c := range s.counters[1:] {
+		if c.Count > max.Count || (c.Count == max.Count && c.Item < max.Item) {
+			max = c
+		}
+	}
+	return max, true
+}
+
+// Counter returns the counter of item, and false if it isn't monitored.
+func (s *SpaceSaving) Counter(item int) (SpaceSavingCounter, bool) {
+	i, ok := s.index[item]
+	if !ok {
+		return SpaceSavingCounter{}, false
+	}
+	return s.counters[i], true
+}
+
+// Capacity returns the maximum number of monitored items.
+func (s *SpaceSaving) Capacity() int {
+	return s.capacity
+}
+
+// MarshalJSON encodes the sketch with its counters.
+func (s *SpaceSaving) MarshalJSON() ([]byte, error) {
+	return json.Marshal(spaceSavingJSON{Capacity: s.capacity, Counters: s.counters})
+}
+
+// UnmarshalJSON decodes the sketch and rebuilds its index.
+func (s *SpaceSaving) UnmarshalJSON(data []byte) error {
+	var decoded spaceSavingJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+
+	*s = *NewSpaceSavingWithCapacity(decoded.Capacity)
+	s.counters = decoded.Counters
+	for i, c := range s.counters {
+		s.index[c.Item] = i
+	}
+	heap.Init(&indexedHeap{s})
+	return nil
+}
+
+type spaceSavingJSON struct {
+	Capacity int                  `json:"capacity"`
+	Counters []SpaceSavingCounter `json:"counters"`
+}
+
+// fix restores the heap order after the counter at i changed.
+func (s *SpaceSaving) fix(i int) {
+	heap.Fix(&indexedHeap{s}, i)
+}
+
+// counterHeap is a min-heap of counters ordered by count.
+type counterHeap []SpaceSavingCounter
+
+// indexedHeap implements heap.Interface, keeping the item index up to date.
+type indexedHeap struct {
+	s *SpaceSaving
+}
+
+func (h *indexedHeap) Len() int { return len(h.s.counters) }
+
+func (h *indexedHeap) Less(i, j int) bool { return h.s.counters[i].Count < h.s.counters[j].Count }
+
+func (h *indexedHeap) Swap(i, j int) {
+	c := h.s.counters
+	c[i], c[j] = c[j], c[i]
+	h.s.index[c[i].Item] = i
+	h.s.index[c[j].Item] = j
+}
+
+func (h *indexedHeap) Push(x interface{}) {
+	h.s.counters = append(h.s.counters, x.(SpaceSavingCounter))
+}
+
+func (h *indexedHeap) Pop() interface{} {
+	old := h.s.counters
+	c := old[len(old)-1]
+	h.s.counters = old[:len(old)-1]
+	return c
+}
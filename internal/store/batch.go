@@ -0,0 +1,128 @@
+package store
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+	"github.com/rs/zerolog/log"
+)
+
+// EventInserter stores batches of events.
+type EventInserter interface {
+	InsertEvents(ctx context.Context, events []casino.Event) (int, error)
+}
+
+const (
+	// maxAttempts is the number of failed flushes after which the buffered
+	// events are dropped.
+	maxAttempts = 5
+	// maxBatches bounds the buffer while inserts fail. The oldest events
+	// beyond it are dropped.
+	maxBatches = 10
+)
+
+// BatchWriter buffers events and inserts them in batches, either when the
+// batch is full or when the flush interval passes.
+type BatchWriter struct {
+	inserter EventInserter
+	size     int
+
+	mu       sync.Mutex
+	buffer   []casino.Event
+	attempts int
+}
+
+// NewBatchWriter creates a new BatchWriter inserting batches of up to size events.
+func NewBatchWriter(inserter EventInserter, size int) *BatchWriter {
+	if size < 1 {
+		size = 1
+	}
+	return &BatchWriter{inserter: inserter, size: size}
+}
+
+// Add buffers an event, flushing the batch once it is full. After a failed
+// flush, retries are left to Run.
+func (w *BatchWriter) Add(ctx context.Context, event casino.Event) error {
+	w.mu.Lock()
+	w.buffer = append(w.buffer, event)
+	w.trim()
+	full := len(w.buffer) >= w.size && w.attempts == 0
+	w.mu.Unlock()
+
+	if full {
+		return w.Flush(ctx)
+	}
+	return nil
+}
+
+// Flush inserts the buffered events. On failure the events are kept for the
+// next flush, up to maxAttempts flushes and maxBatches batches.
+func (w *BatchWriter) Flush(ctx context.Context) error {
+	w.mu.Lock()
+	events := w.buffer
+	w.buffer = nil
+	w.mu.Unlock()
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	inserted, err := w.inserter.InsertEvents(ctx, events)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		w.attempts++
+		if w.attempts >= maxAttempts {
+			w.attempts = 0
+			drop(len(events), err)
+			return err
+		}
+		w.buffer = append(events, w.buffer...)
+		w.trim()
+		return err
+	}
+	w.attempts = 0
+
+	if skipped := len(events) - inserted; skipped > 0 {
+		log.Info().Msgf("Skipped %d already stored events", skipped)
+	}
+	return nil
+}
+
+// trim drops the oldest events beyond maxBatches batches. Callers hold mu.
+func (w *BatchWriter) trim() {
+	if excess := len(w.buffer) - maxBatches*w.size; excess > 0 {
+		drop(excess, nil)
+		w.buffer = w.buffer[excess:]
+	}
+}
+
+// drop logs and counts events that won't be stored.
+func drop(n int, err error) {
+	log.Error().Err(err).Msgf("Dropped %d events that could not be stored", n)
+	metrics.EventsDropped.Add(float64(n), "store")
+}
+
+// Run flushes the buffer periodically until the context is done,
+// and flushes once more before returning.
+func (w *BatchWriter) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if err := w.Flush(context.Background()); err != nil {
+				log.Error().Err(err).Msg("Failed to store events")
+			}
+			return
+		case <-ticker.C:
+			if err := w.Flush(ctx); err != nil {
+				log.Error().Err(err).Msg("Failed to store events")
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/metrics"
+)
+
+type fakeInserter struct {
+	batches [][]casino.Event
+	err     error
+}
+
+func (f *fakeInserter) InsertEvents(ctx context.Context, events []casino.Event) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	}
+	f.batches = append(f.batches, events)
+	return len(events), nil
+}
+
+func TestBatchWriter(t *testing.T) {
+	inserter := &fakeInserter{}
+	writer := NewBatchWriter(inserter, 2)
+	ctx := context.Background()
+
+	for i := 1; i <= 3; i++ {
+		if err := writer.Add(ctx, casino.Event{ID: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(inserter.batches) != 1 || len(inserter.batches[0]) != 2 {
+		t.Fatalf("Expected one full batch of 2 events, got %+v", inserter.batches)
+	}
+
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserter.batches) != 2 || inserter.batches[1][0].ID != 3 {
+		t.Errorf("Expected the remaining event to be flushed, got %+v", inserter.batches)
+	}
+}
+
+func TestBatchWriterRetriesFailedBatch(t *testing.T) {
+	inserter := &fakeInserter{err: errors.New("database unavailable")}
+	writer := NewBatchWriter(inserter, 10)
+	ctx := context.Background()
+
+	_ = writer.Add(ctx, casino.Event{ID: 1})
+	if err := writer.Flush(ctx); err == nil {
+		t.Fatal("Expected flush to fail")
+	}
+
+	inserter.err = nil
+	_ = writer.Add(ctx, casino.Event{ID: 2})
+	if err := writer.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(inserter.batches) != 1 || len(inserter.batches[0]) != 2 || inserter.batches[0][0].ID != 1 {
+		t.Errorf("Expected the failed event to be retried first, got %+v", inserter.batches)
+	}
+}
+
+func TestBatchWriterDropsFailedBatch(t *testing.T) {
+	inserter := &fakeInserter{err: errors.New("database unavailable")}
+	writer := NewBatchWriter(inserter, 2)
+	ctx := context.Background()
+	dropped := metrics.EventsDropped.Value("store")
+
+	// A failed flush leaves the retries to Run, so the buffer grows up to its bound.
+	for i := 1; i <= maxBatches*2+3; i++ {
+		_ = writer.Add(ctx, casino.Event{ID: i})
+	}
+	if len(writer.buffer) != maxBatches*2 || writer.buffer[0].ID != 4 {
+		t.Fatalf("Expected the %d newest events to be buffered, got %d from event %d", maxBatches*2, len(writer.buffer), writer.buffer[0].ID)
+	}
+
+	for i := 1; i < maxAttempts; i++ {
+		if err := writer.Flush(ctx); err == nil {
+			t.Fatal("Expected flush to fail")
+		}
+	}
+	if len(writer.buffer) != 0 {
+		t.Errorf("Expected the buffer to be dropped after %d attempts, got %d events", maxAttempts, len(writer.buffer))
+	}
+	if got := metrics.EventsDropped.Value("store") - dropped; got != maxBatches*2+3 {
+		t.Errorf("Expected %d dropped events, got %v", maxBatches*2+3, got)
+	}
+
+	inserter.err = nil
+	if err := writer.Add(ctx, casino.Event{ID: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Add(ctx, casino.Event{ID: 101}); err != nil {
+		t.Fatal(err)
+	}
+	if len(inserter.batches) != 1 || inserter.batches[0][0].ID != 100 {
+		t.Errorf("Expected full batches to be flushed again, got %+v", inserter.batches)
+	}
+}
@@ -0,0 +1,111 @@
+// Package store This persists enriched events in an append-only Postgres table.
+package store
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Bitstarz-eng/event-processing-challenge/internal/casino"
+	"github.com/lib/pq" // PostgreSQL driver
+)
+
+// EventRepository handles database operations related to stored events.
+type EventRepository struct {
+	db *sql.DB
+}
+
+// NewEventRepository creates a new EventRepository instance.
+func NewEventRepository(databaseURL string) (*EventRepository, error) {
+	db, err := sql.Open("postgres", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to database: %w", err)
+	}
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+	return &EventRepository{db: db}, nil
+}
+
+// Close closes the database connection.
+func (r *EventRepository) Close() error {
+	return r.db.Close()
+}
+
+// InsertEvents stores the events in a single statement and returns how many
+// were new. Events are identified by their ID and creation time, since the
+// generator restarts IDs on every run, so inserting an event again is a no-op.
+func (r *EventRepository) InsertEvents(ctx context.Context, events []casino.Event) (int, error) {
+	if len(events) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]int64, len(events))
+	createdAt := make([]string, len(events))
+	playerIDs := make([]int64, len(events))
+	gameIDs := make([]sql.NullInt64, len(events))
+	types := make([]string, len(events))
+	data := make([]string, len(events))
+	for i, event := range events {
+		encoded, err := json.Marshal(event)
+		if err != nil {
+			return 0, fmt.Errorf("error encoding event %d: %w", event.ID, err)
+		}
+		ids[i] = int64(event.ID)
+		createdAt[i] = event.CreatedAt.Format(time.RFC3339Nano)
+		playerIDs[i] = int64(event.PlayerID)
+		gameIDs[i] = sql.NullInt64{Int64: int64(event.GameID), Valid: event.GameID != 0}
+		types[i] = event.Type
+		data[i] = string(encoded)
+	}
+
+	query := `INSERT INTO events (id, created_at, player_id, game_id, type, data)
+		SELECT * FROM unnest($1::bigint[], $2::timestamptz[], $3::bigint[], $4::bigint[], $5::text[], $6::jsonb[])
+		ON CONFLICT DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query,
+		pq.Array(ids), pq.Array(createdAt), pq.Array(playerIDs), pq.Array(gameIDs), pq.Array(types), pq.Array(data))
+	if err != nil {
+		return 0, fmt.Errorf("error inserting events: %w", err)
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error inserting events: %w", err)
+	}
+	return int(inserted), nil
+}
+
+// ReadEvents calls fn for every stored event created within [from, to), in
+// order of creation. A zero from or to leaves that side of the range open.
+func (r *EventRepository) ReadEvents(ctx context.Context, from, to time.Time, fn func(casino.Event) error) error {
+	query := `SELECT data FROM events
+		WHERE ($1::timestamptz IS NULL OR created_at >= $1)
+		AND ($2::timestamptz IS NULL OR created_at < $2)
+		ORDER BY created_at, id`
+	rows, err := r.db.QueryContext(ctx, query, nullTime(from), nullTime(to))
+	if err != nil {
+		return fmt.Errorf("error reading events: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return fmt.Errorf("error reading events: %w", err)
+		}
+		var event casino.Event
+		if err := json.Unmarshal(data, &event); err != nil {
+			return fmt.Errorf("error decoding event: %w", err)
+		}
+		if err := fn(event); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}